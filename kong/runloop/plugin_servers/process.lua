@@ -66,22 +66,28 @@ local function query_external_plugin_info(server_def)
   end
 
   server_def.protocol = dump.Protocol or "MsgPack:1"
-  local info = (dump.Plugins or dump)[1] -- XXX can a pluginserver (in the embedded plugin server world
-                                        -- have more than one plugin? only a single
-                                        -- configuration is initialized currently, so this
-                                        -- seems to be legacy code)
 
   -- in remote times, a plugin server could serve more than one plugin
   -- nowadays (2.8+), external plugins use an "embedded pluginserver" model, where
-  -- each plugin acts as an independent plugin server
-  return {
-    server_def = server_def,
-    name = info.Name,
-    PRIORITY = info.Priority,
-    VERSION = info.Version,
-    schema = info.Schema,
-    phases = info.Phases,
-  }
+  -- each plugin acts as an independent plugin server; servers listing several
+  -- plugins are still supported, all of them sharing the process and socket
+  local infos = {}
+  for i, info in ipairs(dump.Plugins or dump) do
+    infos[i] = {
+      server_def = server_def,
+      name = info.Name,
+      PRIORITY = info.Priority,
+      VERSION = info.Version,
+      schema = info.Schema,
+      phases = info.Phases,
+    }
+  end
+
+  if #infos == 0 then
+    return nil, string.format("no plugins listed by pluginserver %s: \n%s", server_def.name, infos_dump)
+  end
+
+  return infos
 end
 
 
@@ -90,16 +96,20 @@ function _M.load_external_plugins_info(kong_conf)
 
   kong.log.notice("[pluginserver] loading external plugins info")
 
+  local n = 0
   for _, pluginserver in ipairs(kong_conf.pluginservers) do
-    local plugin_info, err = query_external_plugin_info(pluginserver)
-    if not plugin_info then
+    local plugin_infos, err = query_external_plugin_info(pluginserver)
+    if not plugin_infos then
       return nil, err
     end
 
-    available_external_plugins[plugin_info.name] = plugin_info
+    for _, plugin_info in ipairs(plugin_infos) do
+      available_external_plugins[plugin_info.name] = plugin_info
+      n = n + 1
+    end
   end
 
-  kong.log.notice("[pluginserver] loaded #", #kong_conf.pluginservers, " external plugins info")
+  kong.log.notice("[pluginserver] loaded #", n, " external plugins info")
 
   return available_external_plugins
 end
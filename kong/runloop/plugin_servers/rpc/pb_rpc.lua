@@ -34,7 +34,16 @@ do
       return structpb_struct(v.struct_value)
     end
 
-    return v.bool_value or v.string_value or v.number_value or v.null_value
+    if v.null_value ~= nil then
+      return nil
+    end
+
+    -- `false` must not fall through to the other kinds
+    if v.bool_value ~= nil then
+      return v.bool_value
+    end
+
+    return v.string_value or v.number_value
   end
 
   function structpb_list(l)
@@ -0,0 +1,88 @@
+local cjson = require "cjson"
+local tablex = require "pl.tablex"
+
+
+-- a pluginserver whose -dump prints `dump`
+local function server_def(name, dump)
+  return {
+    name = name,
+    socket = "/tmp/" .. name .. ".socket",
+    query_command = "printf '%s' '" .. cjson.encode(dump) .. "'",
+  }
+end
+
+
+local function plugin(name, priority)
+  return {
+    Name = name,
+    Priority = priority,
+    Version = "0.1",
+    Schema = { name = name, fields = {} },
+    Phases = { "access" },
+  }
+end
+
+
+describe("pluginserver info", function()
+  local process
+  local old_kong
+
+  lazy_setup(function()
+    old_kong = _G.kong
+    _G.kong = {
+      log = {
+        notice = function() end,
+      },
+    }
+    package.loaded["kong.runloop.plugin_servers.process"] = nil
+    process = require "kong.runloop.plugin_servers.process"
+  end)
+
+  lazy_teardown(function()
+    _G.kong = old_kong
+    package.loaded["kong.runloop.plugin_servers.process"] = nil
+  end)
+
+  it("loads the single plugin of embedded pluginservers", function()
+    local hello = server_def("go-hello", {
+      Protocol = "ProtoBuf:1",
+      Plugins = { plugin("go-hello", 1) },
+    })
+
+    local infos = assert(process.load_external_plugins_info({ pluginservers = { hello } }))
+    assert.same({ "go-hello" }, tablex.keys(infos))
+    assert.equal(hello, infos["go-hello"].server_def)
+    assert.equal("ProtoBuf:1", hello.protocol)
+    assert.equal(1, infos["go-hello"].PRIORITY)
+    assert.equal("0.1", infos["go-hello"].VERSION)
+    assert.same({ "access" }, infos["go-hello"].phases)
+  end)
+
+  it("loads every plugin of pluginservers hosting several", function()
+    local multi = server_def("go-multi", {
+      Protocol = "ProtoBuf:1",
+      Plugins = { plugin("go-first", 20), plugin("go-second", 10) },
+    })
+    local legacy = server_def("legacy", { plugin("legacy-a", 5), plugin("legacy-b", 6) })
+
+    local infos = assert(process.load_external_plugins_info({ pluginservers = { multi, legacy } }))
+
+    -- plugins of the same server share its process and socket
+    assert.equal(multi, infos["go-first"].server_def)
+    assert.equal(multi, infos["go-second"].server_def)
+    assert.equal(20, infos["go-first"].PRIORITY)
+    assert.equal(10, infos["go-second"].PRIORITY)
+
+    assert.equal(legacy, infos["legacy-a"].server_def)
+    assert.equal(legacy, infos["legacy-b"].server_def)
+    assert.equal("MsgPack:1", legacy.protocol)
+  end)
+
+  it("fails on pluginservers listing no plugin", function()
+    local empty = server_def("empty", { Protocol = "ProtoBuf:1", Plugins = {} })
+
+    local infos, err = process.load_external_plugins_info({ pluginservers = { empty } })
+    assert.is_nil(infos)
+    assert.matches("no plugins listed by pluginserver empty", err, nil, true)
+  end)
+end)
@@ -0,0 +1,108 @@
+local helpers = require "spec.helpers"
+local pl_path = require "pl.path"
+
+
+-- grpcurl gives up after this, Kong must answer well before
+local MAX_TIME = 10
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("gRPC upstream protocol faults [#" .. strategy .. "]", function()
+    local proxy_client_grpc
+
+    lazy_setup(function()
+      assert(helpers.start_grpc_target())
+
+      -- start_grpc_target takes long time, the db socket might already
+      -- be timeout, so we close it to avoid `db:init_connector` failing
+      -- in `helpers.get_db_utils`
+      helpers.db:connect()
+      helpers.db:close()
+
+      local bp = helpers.get_db_utils(strategy, {
+        "routes",
+        "services",
+      })
+
+      local service = assert(bp.services:insert {
+        protocol = "grpc",
+        host = "127.0.0.1",
+        port = helpers.get_grpc_target_faults_port(),
+        retries = 0,
+      })
+
+      assert(bp.routes:insert {
+        protocols = { "grpc" },
+        hosts = { "faults.test" },
+        service = service,
+      })
+
+      assert(helpers.start_kong({
+        database = strategy,
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+      }))
+
+      proxy_client_grpc = helpers.proxy_client_grpc()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+      helpers.stop_grpc_target()
+    end)
+
+    -- calls SayHello through Kong, asking the target for a fault
+    local function say_hello(fault)
+      local proto = helpers.get_grpc_target_proto_path()
+      return proxy_client_grpc({
+        service = "targetservice.Bouncer.SayHello",
+        body = { greeting = "world" },
+        opts = {
+          ["-import-path"] = pl_path.dirname(proto),
+          ["-proto"] = pl_path.basename(proto),
+          ["-authority"] = "faults.test",
+          ["-max-time"] = MAX_TIME,
+          ["-H"] = fault and string.format("'x-target-fault: %s'", fault),
+        },
+      })
+    end
+
+    it("proxies calls without a fault", function()
+      local ok, out = say_hello()
+      assert.truthy(ok, out)
+      assert.matches([["reply": "hello world"]], out, nil, true)
+    end)
+
+    it("answers 502 when the upstream closes before its headers", function()
+      local ok, out = say_hello("close_before_headers")
+      assert.falsy(ok)
+      -- grpcurl's mapping of the 502
+      assert.matches("Code: Unavailable", out, nil, true)
+      assert.logfile().has.line("upstream prematurely closed connection while reading response header from upstream", true, 10)
+    end)
+
+    for _, fault in ipairs({
+      "rst_stream",
+      "trailers_only_no_status",
+      "truncated_frame",
+      "wrong_content_type",
+      "close_after_headers",
+    }) do
+      it("fails calls answered with a " .. fault .. " fault without hanging", function()
+        ngx.update_time()
+        local started = ngx.now()
+        local ok, out = say_hello(fault)
+        ngx.update_time()
+
+        assert.falsy(ok, out)
+        assert.matches("Code: ", out, nil, true)
+        assert.not_matches("DeadlineExceeded", out, nil, true)
+        assert.is_true(ngx.now() - started < MAX_TIME)
+      end)
+    end
+
+    it("logs the upstream reset of rst_stream faults", function()
+      say_hello("rst_stream")
+      assert.logfile().has.line("upstream rejected request with error 2", true, 10)
+    end)
+  end)
+end
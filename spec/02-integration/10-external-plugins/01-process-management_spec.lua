@@ -92,8 +92,37 @@ for _, strategy in helpers.each_strategy() do
         assert.equal(1, info.PRIORITY)
         assert.equal("0.1", info.VERSION)
         assert.equal("go-hello", info.name)
-        assert.same({ "access", "response", "log" }, info.phases)
+        assert.same({ "certificate", "rewrite", "access", "response", "log" }, info.phases)
         assert.same("ProtoBuf:1", info.server_def.protocol)
+
+        -- schema fields are lists of { [name] = definition }
+        local function fields(list)
+          local by_name = {}
+          for _, field in ipairs(list) do
+            local name, def = next(field)
+            by_name[name] = def
+          end
+          return by_name
+        end
+
+        local config = fields(info.schema.fields).config
+        assert.equal("record", config.type)
+
+        local config_fields = fields(config.fields)
+        assert.same({ type = "string" }, config_fields.message)
+        assert.same({ type = "integer" }, config_fields.count)
+        assert.same({ type = "number" }, config_fields.ratio)
+        assert.same({ type = "boolean" }, config_fields.enabled)
+        assert.same({ type = "array", elements = { type = "string" } }, config_fields.tags)
+        assert.same({ type = "map", keys = { type = "string" }, values = { type = "string" } }, config_fields.labels)
+        assert.equal("integer", config_fields.timeout.type)
+        assert.equal("string", config_fields.note.type)
+
+        assert.equal("record", config_fields.limits.type)
+        local limits_fields = fields(config_fields.limits.fields)
+        assert.same({ type = "integer" }, limits_fields.second)
+        assert.same({ type = "integer" }, limits_fields.minute)
+        assert.same({ type = "string" }, limits_fields.policy)
     end)
 
     it("queries plugin info [python]", function()
@@ -146,7 +175,7 @@ for _, strategy in helpers.each_strategy() do
         assert.equal(1, go_info.PRIORITY)
         assert.equal("0.1", go_info.VERSION)
         assert.equal("go-hello", go_info.name)
-        assert.same({ "access", "response", "log" }, go_info.phases)
+        assert.same({ "certificate", "rewrite", "access", "response", "log" }, go_info.phases)
         assert.same("ProtoBuf:1", go_info.server_def.protocol)
 
         local py_info = plugin_infos["py-hello"]
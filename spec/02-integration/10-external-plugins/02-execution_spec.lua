@@ -1,4 +1,5 @@
 local helpers = require "spec.helpers"
+local cjson = require "cjson"
 
 for _, strategy in helpers.each_strategy() do
   describe("plugin triggering #" .. strategy, function()
@@ -11,10 +12,15 @@ for _, strategy in helpers.each_strategy() do
 
       assert(bp.services:insert {})
       assert(bp.routes:insert({
-        protocols = { "http" },
+        protocols = { "http", "https" },
         paths = { "/" }
       }))
 
+      local typed_route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/typed" }
+      }))
+
       local kong_prefix = helpers.test_conf.prefix
 
       assert(helpers.start_kong({
@@ -45,6 +51,28 @@ for _, strategy in helpers.each_strategy() do
       })
       assert.res_status(201, res)
 
+      res = admin_client:post("/plugins", {
+        headers = {
+          ["Content-Type"] = "application/json"
+        },
+        body = {
+          name = "go-hello",
+          route = { id = typed_route.id },
+          config = {
+            message = "typed",
+            count = 42,
+            ratio = 2.75,
+            enabled = false,
+            tags = { "a", "b", "c" },
+            labels = { env = "test", team = "gateway" },
+            limits = { second = 5, minute = 100, policy = "cluster" },
+            timeout = 30,
+            note = "not null",
+          }
+        }
+      })
+      assert.res_status(201, res)
+
       res = admin_client:post("/plugins", {
         headers = {
           ["Content-Type"] = "application/json"
@@ -72,5 +100,58 @@ for _, strategy in helpers.each_strategy() do
       h = assert.response(res).has.header("x-hello-from-python")
       assert.matches("Python says Kong! to", h)
     end)
+
+    it("executes the rewrite phase of global external plugins [golang]", function()
+      local proxy_client = assert(helpers.proxy_client())
+      local res = proxy_client:get("/rewrite?foo=bar")
+      assert.res_status(200, res)
+      local h = assert.response(res).has.header("x-hello-from-go-at-rewrite")
+      assert.equal("GET /rewrite sni= client_verify= client_dn=", h)
+    end)
+
+    it("executes the certificate phase of global external plugins [golang]", function()
+      local proxy_client = assert(helpers.proxy_ssl_client(nil, "go-hello.test"))
+      local res = proxy_client:get("/")
+      assert.res_status(200, res)
+      local h = assert.response(res).has.header("x-hello-from-go-at-rewrite")
+      assert.equal("GET / sni=go-hello.test client_verify=NONE client_dn=", h)
+      assert.logfile().has.line([=[go-hello certificate phase, handshake version TLSv1\.[23]]=])
+    end)
+
+    it("decodes every type of config field [golang]", function()
+      local proxy_client = assert(helpers.proxy_client())
+      local res = proxy_client:get("/typed")
+      assert.res_status(200, res)
+      local h = assert.response(res).has.header("x-hello-from-go-config")
+      assert.same({
+        message = "typed",
+        count = 42,
+        ratio = 2.75,
+        enabled = false,
+        tags = { "a", "b", "c" },
+        labels = { env = "test", team = "gateway" },
+        limits = { second = 5, minute = 100, policy = "cluster" },
+        timeout = 30,
+        note = "not null",
+      }, cjson.decode(h))
+    end)
+
+    it("keeps the Go defaults of unset config fields [golang]", function()
+      local proxy_client = assert(helpers.proxy_client())
+      local res = proxy_client:get("/")
+      assert.res_status(200, res)
+      local h = assert.response(res).has.header("x-hello-from-go-config")
+      assert.same({
+        message = "Kong!",
+        count = 1,
+        ratio = 0.5,
+        enabled = true,
+        tags = cjson.null,
+        labels = cjson.null,
+        limits = { second = 10, minute = 0, policy = "local" },
+        timeout = cjson.null,
+        note = cjson.null,
+      }, cjson.decode(h))
+    end)
   end)
 end
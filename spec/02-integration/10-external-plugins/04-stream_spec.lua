@@ -0,0 +1,116 @@
+local helpers = require "spec.helpers"
+
+local MESSAGE = "echo, ping, pong. echo, ping, pong. echo, ping, pong.\n"
+
+for _, strategy in helpers.each_strategy() do
+  describe("stream plugin triggering #" .. strategy, function()
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local tcp_srv = bp.services:insert({
+        name = "tcp",
+        host = helpers.mock_upstream_host,
+        port = helpers.mock_upstream_stream_port,
+        protocol = "tcp",
+      })
+
+      local tls_srv = bp.services:insert({
+        name = "tls",
+        host = helpers.mock_upstream_host,
+        port = helpers.mock_upstream_stream_ssl_port,
+        protocol = "tls",
+      })
+
+      local tcp_route = bp.routes:insert({
+        destinations = { { port = 19000 } },
+        protocols = { "tcp" },
+        service = tcp_srv,
+      })
+
+      local tls_route = bp.routes:insert({
+        destinations = { { port = 19443 } },
+        protocols = { "tls" },
+        service = tls_srv,
+      })
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-stream",
+        stream_listen = helpers.get_proxy_ip(false) .. ":19000," ..
+                        helpers.get_proxy_ip(false) .. ":19443 ssl",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-stream.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-stream -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-stream -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+
+      for route, config in pairs({
+        [tcp_route.id] = {},
+        [tls_route.id] = { deny_snis = { "denied.test" } },
+      }) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-stream",
+            route = { id = route },
+            protocols = { "tcp", "tls" },
+            config = config,
+          },
+        })
+        assert.res_status(201, res)
+      end
+
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+    end)
+
+    it("executes the preread phase of external plugins [golang]", function()
+      local tcp = ngx.socket.tcp()
+      assert(tcp:connect(helpers.get_proxy_ip(false), 19000))
+      assert(tcp:send(MESSAGE))
+      local body = assert(tcp:receive("*a"))
+      assert.equal(MESSAGE, body)
+      tcp:close()
+
+      assert.logfile().has.line([[go-stream preread: client=127\.0\.0\.1:[0-9]+ sni=(,| |$)]])
+    end)
+
+    it("sees the SNI of TLS connections [golang]", function()
+      local tcp = ngx.socket.tcp()
+      assert(tcp:connect(helpers.get_proxy_ip(true), 19443))
+      assert(tcp:sslhandshake(nil, "allowed.test", false))
+      assert(tcp:send(MESSAGE))
+      local body = assert(tcp:receive("*a"))
+      assert.equal(MESSAGE, body)
+      tcp:close()
+
+      assert.logfile().has.line([[go-stream preread: client=127\.0\.0\.1:[0-9]+ sni=allowed\.test]])
+    end)
+
+    it("rejects connections denied by its config [golang]", function()
+      local tcp = ngx.socket.tcp()
+      assert(tcp:connect(helpers.get_proxy_ip(true), 19443))
+      assert(tcp:sslhandshake(nil, "denied.test", false))
+      assert(tcp:send(MESSAGE))
+      local body = tcp:receive("*a")
+      assert.not_equal(MESSAGE, body)
+      tcp:close()
+
+      assert.logfile().has.line([[go-stream rejecting client=127\.0\.0\.1 sni=denied\.test]])
+    end)
+  end)
+end
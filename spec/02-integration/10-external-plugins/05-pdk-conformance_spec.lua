@@ -0,0 +1,364 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+local pl_dir = require "pl.dir"
+local pl_file = require "pl.file"
+local phase_checker = require "kong.pdk.private.phases"
+local bit = require "bit"
+
+
+local PHASES = phase_checker.phases
+
+-- calls that can't succeed in this setup: there is no Upstream entity and
+-- the proxy is plain HTTP
+local EXPECTED_ERRORS = {
+  ["service.set_upstream"] = true,
+  ["nginx.get_tls1_version_str"] = true,
+}
+
+-- calls whose result can't be in a report, checked by their own test
+local REPORTED_ELSEWHERE = {
+  ["response.exit"] = "exits from the access phase",
+}
+
+
+-- every RPC of the Kong service of the pluginserver protocol, named as in
+-- the report: Service_Request_SetHeader is "service.request.set_header"
+local function pdk_functions()
+  local proto = assert(pl_file.read("kong/include/kong/pluginsocket.proto"))
+  local service = assert(proto:match("service Kong%s*(%b{})"))
+
+  local names = {}
+  for rpc in service:gmatch("rpc%s+([%w_]+)%s*%(") do
+    local parts = {}
+    for part in rpc:gmatch("[^_]+") do
+      parts[#parts + 1] = part
+    end
+
+    local fn = parts[#parts]:gsub("(%l)(%u)", "%1_%2"):gsub("(%d)(%u)", "%1_%2")
+    parts[#parts] = fn
+    names[#names + 1] = table.concat(parts, "."):lower()
+  end
+
+  assert(#names > 0, "no RPC found in pluginsocket.proto")
+  return names
+end
+
+
+-- the phases each PDK function checks it is called in, from the
+-- check_phase and check_not_phase calls of kong/pdk, by report name:
+-- kong/pdk/service/request.lua set_header is "service.request.set_header"
+local function pdk_phases()
+  local checks = {}
+  for _, file in ipairs(pl_dir.getallfiles("kong/pdk", "*.lua")) do
+    local module = file:match("^kong/pdk/(.+)%.lua$"):gsub("/", ".")
+    if not module:match("^private%.") then
+      local src = assert(pl_file.read(file))
+
+      -- phases sets named by locals, like response.lua header_body_log,
+      -- which may use aliases like log.lua PHASES_LOG
+      local sets = {}
+      local env = setmetatable({ phase_checker = phase_checker, PHASES = PHASES },
+                               { __index = sets })
+      local function eval(expr)
+        return assert(load("return " .. expr, expr, "t", env))()
+      end
+
+      for name, expr in src:gmatch("local%s+([%w_]+)%s*=%s*(PHASES%.[%w_]+)") do
+        sets[name] = eval(expr)
+      end
+      for name, expr in src:gmatch("local%s+([%w_]+)%s*=%s*(phase_checker%.new%b())") do
+        sets[name] = eval(expr)
+      end
+
+      local fn
+      for line in src:gmatch("[^\n]+") do
+        fn = line:match("function%s+[%w_.:]-([%w_]+)%s*%(")
+             or line:match("([%w_]+)%s*=%s*function%s*%(")
+             or fn
+
+        local negated, arg = line:match("check_(n?o?t?_?)phase%(([%w_.]+)%)")
+        local name = fn and module .. "." .. fn
+        if name and arg and not checks[name] then
+          checks[name] = {
+            phases = sets[arg] or eval(arg),
+            negated = negated ~= "",
+          }
+        end
+      end
+    end
+  end
+
+  return checks
+end
+
+
+-- whether a PDK function can be called in phase, according to checks
+local function allowed_in(checks, name, phase)
+  local check = checks[name]
+  if not check then
+    return true
+  end
+
+  local matches = bit.band(check.phases, PHASES[phase]) ~= 0
+  return matches ~= check.negated
+end
+
+
+-- typed Go variants of one RPC are reported each under their own suffix,
+-- like ctx.get_shared_int for Ctx_GetShared
+local function reported(report, name)
+  if report[name] then
+    return true
+  end
+
+  for key in pairs(report) do
+    if key:sub(1, #name + 1) == name .. "_" then
+      return true
+    end
+  end
+
+  return false
+end
+
+
+-- the report of the last log phase, from the error log
+local function log_phase_report()
+  local report
+  helpers.pwait_until(function()
+    local logs = assert(pl_file.read(helpers.test_conf.nginx_err_logs))
+    local last
+    for json in logs:gmatch("pdk conformance log phase: (%b{})") do
+      last = json
+    end
+    report = cjson.decode(assert(last))
+  end, 10)
+
+  return report
+end
+
+for _, strategy in helpers.each_strategy() do
+  describe("go-pdk conformance #" .. strategy, function()
+    local proxy_client
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+        "consumers",
+      }))
+
+      local consumer = assert(bp.consumers:insert({ username = "conformance" }))
+
+      local service = assert(bp.services:insert {})
+      local route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/conformance" },
+        strip_path = false,
+        service = service,
+      }))
+      local exit_route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/conformance-exit" },
+        service = service,
+      }))
+      local captures_route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { [[~/conformance-captures/(?<id>\d+)$]] },
+        service = service,
+      }))
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-conformance",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-conformance.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-conformance -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-conformance -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+
+      for _, plugin in ipairs({
+        { route = route, config = { consumer_id = consumer.id, upstream = "no-such-upstream" } },
+        { route = exit_route, config = { consumer_id = consumer.id, exit_status = 418 } },
+        { route = captures_route, config = { consumer_id = consumer.id, exit_status = 418 } },
+      }) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-conformance",
+            route = { id = plugin.route.id },
+            config = plugin.config,
+          }
+        })
+        assert.res_status(201, res)
+      end
+
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    local function send()
+      local res = proxy_client:post("/conformance?q=1", {
+        headers = {
+          ["Content-Type"] = "text/plain",
+          ["X-Conformance-In"] = "yes",
+        },
+        body = "hello",
+      })
+      local body = assert.res_status(200, res)
+      local report = cjson.decode(assert.response(res).has.header("x-pdk-conformance"))
+      return report, cjson.decode(body)
+    end
+
+    it("calls every PDK function without errors [golang]", function()
+      local report = send()
+
+      for name, result in pairs(report) do
+        if EXPECTED_ERRORS[name] then
+          assert.is_string(result.error, name .. " should fail")
+        else
+          assert.is_nil(result.error, name .. ": " .. tostring(result.error))
+        end
+      end
+
+      -- a missing function fails here as soon as it is in the protocol
+      local log_report = log_phase_report()
+      for _, name in ipairs(pdk_functions()) do
+        if not REPORTED_ELSEWHERE[name] then
+          assert.is_true(reported(report, name) or reported(log_report, name),
+                         name .. " missing from the report")
+        end
+      end
+    end)
+
+    it("calls each PDK function in a phase that allows it [golang]", function()
+      local checks = pdk_phases()
+      -- the parser found the restricted functions
+      assert.not_nil(checks["response.get_status"])
+      assert.not_nil(checks["client.authenticate"])
+      assert.not_nil(checks["service.request.set_header"])
+      assert.not_nil(checks["log.serialize"])
+      assert.is_false(allowed_in(checks, "response.get_status", "access"))
+
+      local report = send()
+      local log_report = log_phase_report()
+      for _, r in ipairs({ report, log_report }) do
+        for name, result in pairs(r) do
+          assert.is_string(result.phase, name .. " has no phase")
+          assert.is_true(allowed_in(checks, name, result.phase),
+                         name .. " called in the " .. result.phase .. " phase")
+        end
+      end
+
+      assert.equal("access", report["client.authenticate"].phase)
+      assert.equal("response", report["response.get_status"].phase)
+      assert.equal("log", log_report["log.serialize"].phase)
+    end)
+
+    it("gets the right values and types back [golang]", function()
+      local report = send()
+
+      local function check(name, value, type)
+        assert.same(value, report[name].value, name)
+        assert.equal(type, report[name].type, name)
+      end
+
+      check("client.get_ip", "127.0.0.1", "string")
+      check("client.get_protocol", "http", "string")
+      check("ip.is_trusted", true, "bool")
+      check("ctx.get_shared_string", "shared", "string")
+      check("ctx.get_shared_int", 42, "int")
+      check("ctx.get_shared_float", 4.5, "float64")
+      check("nginx.get_var", "/conformance?q=1", "string")
+      check("nginx.get_ctx_string", "ctx", "string")
+      check("nginx.get_ctx_int", 7, "int")
+      check("nginx.get_ctx_float", 0.25, "float64")
+      check("nginx.get_subsystem", "http", "string")
+      check("request.get_scheme", "http", "string")
+      check("request.get_port", helpers.get_proxy_port(false), "int")
+      check("request.get_http_version", 1.1, "float64")
+      check("request.get_method", "POST", "string")
+      check("request.get_path", "/conformance", "string")
+      check("request.get_path_with_query", "/conformance?q=1", "string")
+      check("request.get_raw_query", "q=1", "string")
+      check("request.get_query_arg", "1", "string")
+      check("request.get_header", "yes", "string")
+      check("request.get_raw_body", "hello", "string")
+      check("service.response.get_status", 200, "int")
+      check("response.get_status", 200, "int")
+      check("response.get_source", "service", "string")
+
+      assert.equal("conformance", report["client.get_consumer"].value.username)
+      assert.equal("conformance", report["client.get_credential"].value.id)
+      assert.equal("/conformance", report["router.get_route"].value.paths[1])
+      assert.equal(helpers.mock_upstream_host, report["router.get_service"].value.host)
+      assert.is_string(report["node.get_id"].value)
+    end)
+
+    it("changes the upstream request [golang]", function()
+      local _, upstream = send()
+
+      assert.equal("POST", upstream.vars.request_method)
+      assert.equal("/anything", upstream.vars.uri)
+      assert.same({ "1", "2" }, upstream.uri_args.conformance)
+      assert.is_nil(upstream.uri_args.raw)
+      assert.equal("set", upstream.headers["x-conformance"])
+      assert.equal("added", upstream.headers["x-conformance-added"])
+      assert.same({ "a", "b" }, upstream.headers["x-conformance-headers"])
+      assert.is_nil(upstream.headers["x-conformance-in"])
+      assert.equal("conformance body", upstream.post_data.text)
+    end)
+
+    it("calls the PDK from the log phase [golang]", function()
+      send()
+      assert.logfile().has.line([[pdk conformance log phase: .*"log.serialize"]])
+
+      local report = log_phase_report()
+      assert.is_nil(report["log.serialize"].error)
+      local serialized = cjson.decode(report["log.serialize"].value)
+      -- set from the access phase with log.set_serialize_value
+      assert.equal("serialized", serialized.conformance)
+      assert.equal(200, report["response.get_status"].value)
+    end)
+
+    it("gets the URI captures of the route [golang]", function()
+      local res = proxy_client:get("/conformance-captures/42")
+      local report = cjson.decode(assert.res_status(418, res))
+
+      local captures = report["request.get_uri_captures"]
+      assert.is_nil(captures.error)
+      assert.equal("42", captures.value.named.id)
+    end)
+
+    it("exits from the access phase [golang]", function()
+      local res = proxy_client:get("/conformance-exit")
+      local body = assert.res_status(418, res)
+      assert.response(res).has.header("content-type")
+      assert.matches("application/json", res.headers["content-type"], nil, true)
+
+      local report = cjson.decode(body)
+      assert.not_nil(report["request.get_method"])
+      assert.is_nil(report["service.response.get_status"])
+    end)
+  end)
+end
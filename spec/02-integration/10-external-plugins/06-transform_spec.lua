@@ -0,0 +1,145 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+
+for _, strategy in helpers.each_strategy() do
+  describe("short-circuits and transforms from external plugins #" .. strategy, function()
+    local proxy_client
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local service = assert(bp.services:insert {})
+
+      local configs = {
+        ["/exit"] = {
+          exit = {
+            status = 403,
+            body = [[{"message":"go says no"}]],
+            headers = {
+              ["Content-Type"] = "application/json",
+              ["X-Go-Exit"] = "yes",
+            },
+          },
+        },
+        ["/replace"] = {
+          path = "/anything",
+          body = "replaced by go",
+        },
+        ["/inject"] = {
+          path = "/anything",
+          inject = { injected = "by go", a = "overridden" },
+        },
+        ["/rewrite"] = {
+          method = "PUT",
+          path = "/anything/rewritten",
+          query = { x = "1", y = "two" },
+        },
+      }
+
+      local routes = {}
+      for path in pairs(configs) do
+        routes[path] = assert(bp.routes:insert({
+          protocols = { "http" },
+          paths = { path },
+          service = service,
+        }))
+      end
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-transform",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-transform.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-transform -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-transform -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+
+      for path, config in pairs(configs) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-transform",
+            route = { id = routes[path].id },
+            config = config,
+          }
+        })
+        assert.res_status(201, res)
+      end
+
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    it("exits with a status, body and headers [golang]", function()
+      local res = proxy_client:get("/exit")
+      local body = assert.res_status(403, res)
+      assert.same({ message = "go says no" }, cjson.decode(body))
+      assert.equal("yes", res.headers["X-Go-Exit"])
+      assert.matches("application/json", res.headers["Content-Type"], nil, true)
+    end)
+
+    it("replaces the upstream request body [golang]", function()
+      local res = proxy_client:post("/replace", {
+        headers = { ["Content-Type"] = "text/plain" },
+        body = "original",
+      })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("replaced by go", body.post_data.text)
+      assert.equal(tostring(#"replaced by go"), body.headers["content-length"])
+    end)
+
+    it("injects fields into a JSON upstream request body [golang]", function()
+      local res = proxy_client:post("/inject", {
+        headers = { ["Content-Type"] = "application/json" },
+        body = { a = "original", b = "kept" },
+      })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.same({
+        a = "overridden",
+        b = "kept",
+        injected = "by go",
+      }, body.post_data.params)
+    end)
+
+    it("rejects bodies it can't inject fields into [golang]", function()
+      local res = proxy_client:post("/inject", {
+        headers = { ["Content-Type"] = "text/plain" },
+        body = "not json",
+      })
+      local body = assert.res_status(400, res)
+      assert.matches("not a JSON object", body, nil, true)
+    end)
+
+    it("changes the upstream method, path and query [golang]", function()
+      local res = proxy_client:get("/rewrite?x=0&z=dropped")
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("PUT", body.vars.request_method)
+      assert.equal("/anything/rewritten", body.vars.uri)
+      assert.same({ x = "1", y = "two" }, body.uri_args)
+    end)
+  end)
+end
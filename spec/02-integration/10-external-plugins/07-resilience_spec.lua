@@ -0,0 +1,170 @@
+local helpers = require "spec.helpers"
+
+for _, strategy in helpers.each_strategy() do
+  describe("misbehaving external plugins #" .. strategy, function()
+    local proxy_client
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local service = assert(bp.services:insert {})
+
+      local configs = {
+        ["/ok"] = {},
+        ["/panic"] = { mode = "panic" },
+        ["/panic-log"] = { mode = "panic", phase = "log" },
+        ["/exit"] = { mode = "exit" },
+        ["/hang"] = { mode = "hang", hang_ms = 5000 },
+        ["/pdk-error"] = { mode = "pdk_error" },
+        ["/leak"] = { mode = "leak", leak = 100 },
+      }
+
+      local routes = {}
+      for path in pairs(configs) do
+        routes[path] = assert(bp.routes:insert({
+          protocols = { "http" },
+          paths = { path },
+          service = service,
+        }))
+      end
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-chaos",
+        -- bounds the wait on a hung pluginserver
+        nginx_http_lua_socket_read_timeout = "2s",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-chaos.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-chaos -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-chaos -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+
+      for path, config in pairs(configs) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-chaos",
+            route = { id = routes[path].id },
+            config = config,
+          }
+        })
+        assert.res_status(201, res)
+      end
+
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    -- the well behaved route works again, once the pluginserver is back
+    local function recovers()
+      helpers.pwait_until(function()
+        local client = helpers.proxy_client()
+        local res = client:get("/ok")
+        assert.res_status(200, res)
+        assert.equal("survived", res.headers["x-go-chaos"])
+        client:close()
+      end, 10)
+    end
+
+    it("serves well behaved routes [golang]", function()
+      local res = proxy_client:get("/ok")
+      assert.res_status(200, res)
+      assert.equal("survived", res.headers["x-go-chaos"])
+    end)
+
+    it("respawns a pluginserver that panics in the access phase [golang]", function()
+      local res = proxy_client:get("/panic")
+      assert.res_status(500, res)
+
+      assert.logfile().has.line([[go-chaos: panic in access]], true, 10)
+      assert.logfile().has.line([[external pluginserver 'test-go' terminated]], true, 10)
+      recovers()
+    end)
+
+    it("respawns a pluginserver that panics in the log phase [golang]", function()
+      local res = proxy_client:get("/panic-log")
+      assert.res_status(200, res)
+      assert.equal("survived", res.headers["x-go-chaos"])
+
+      assert.logfile().has.line([[go-chaos: panic in log]], true, 10)
+      recovers()
+    end)
+
+    it("respawns a pluginserver that exits [golang]", function()
+      local res = proxy_client:get("/exit")
+      assert.res_status(500, res)
+
+      assert.logfile().has.line([[go-chaos: exiting in access]], true, 10)
+      assert.logfile().has.line([[external pluginserver 'test-go' terminated: exit 3]], true, 10)
+      recovers()
+    end)
+
+    it("gives up on a pluginserver that hangs [golang]", function()
+      local res = proxy_client:get("/hang")
+      assert.res_status(500, res)
+      assert.logfile().has.line([[go-chaos: hanging in access]], true, 10)
+      -- the RPC read giving up after nginx_http_lua_socket_read_timeout
+      assert.logfile().has.line([[pluginserver error: timeout]], true, 10)
+
+      -- other requests go through while the hung one is still sleeping
+      res = proxy_client:get("/ok")
+      assert.res_status(200, res)
+    end)
+
+    it("passes PDK errors back to the plugin [golang]", function()
+      local res = proxy_client:get("/pdk-error")
+      assert.res_status(200, res)
+      -- go-pdk's own error for the false set_upstream returns
+      local err = assert.response(res).has.header("x-go-chaos-error")
+      assert.matches("upstream", err:lower(), nil, true)
+      assert.equal("survived", res.headers["x-go-chaos"])
+      assert.logfile().has.line([[go-chaos: PDK error in access: ]], true, 10)
+    end)
+
+    it("keeps serving while the plugin leaks goroutines [golang]", function()
+      local requests, leak = 10, 100
+      -- the leak itself, with room for the pluginserver's own goroutines
+      -- coming and going; connections piling up would blow it
+      local slack = 50
+
+      local first, last
+      for _ = 1, requests do
+        local res = proxy_client:get("/leak")
+        assert.res_status(200, res)
+        local n = assert(tonumber(res.headers["x-go-chaos-goroutines"]))
+        first = first or n
+        last = n
+        assert.is_true(n <= first + requests * leak + slack,
+                       n .. " goroutines, from " .. first)
+      end
+      assert.is_true(last > first)
+
+      local res = proxy_client:get("/ok")
+      assert.res_status(200, res)
+    end)
+  end)
+end
@@ -0,0 +1,201 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+local openssl_mac = require "resty.openssl.mac"
+local pl_file = require "pl.file"
+
+
+local function sign(secret, method, path, date)
+  local mac = openssl_mac.new(secret, "HMAC", nil, "sha256"):final(method .. " " .. path .. "\n" .. date)
+  return ngx.encode_base64(mac)
+end
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("authentication from external plugins #" .. strategy, function()
+    local proxy_client
+    local keys_file = os.tmpname()
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+        "consumers",
+        "acls",
+      }))
+
+      local alice = assert(bp.consumers:insert({ username = "alice" }))
+      local bob = assert(bp.consumers:insert({ username = "bob" }))
+      assert(bp.consumers:insert({ username = "carol" }))
+      -- only ever counted on /limited
+      assert(bp.consumers:insert({ username = "dave" }))
+      assert(bp.consumers:insert({ username = "erin" }))
+
+      assert(bp.acls:insert({ consumer = { id = alice.id }, group = "go" }))
+      assert(bp.acls:insert({ consumer = { id = bob.id }, group = "go" }))
+
+      local service = assert(bp.services:insert {})
+      local route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/auth" },
+        service = service,
+      }))
+
+      bp.plugins:insert({
+        name = "acl",
+        route = { id = route.id },
+        config = { allow = { "go" } },
+      })
+
+      local limited = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/limited" },
+        service = service,
+      }))
+
+      bp.plugins:insert({
+        name = "rate-limiting",
+        route = { id = limited.id },
+        config = { hour = 3, limit_by = "consumer", policy = "local" },
+      })
+
+      assert(pl_file.write(keys_file, cjson.encode({ ["bob-key"] = "bob" })))
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-auth",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-auth.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-auth -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-auth -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+      local res = admin_client:post("/plugins", {
+        headers = {
+          ["Content-Type"] = "application/json"
+        },
+        body = {
+          name = "go-auth",
+          route = { id = route.id },
+          config = {
+            key_names = { "apikey", "x-api-key" },
+            keys = { ["alice-key"] = "alice", ["carol-key"] = "carol", ["ghost-key"] = "ghost" },
+            keys_file = keys_file,
+            hmac_secrets = { alice = "alice-secret" },
+          },
+        }
+      })
+      assert.res_status(201, res)
+
+      res = admin_client:post("/plugins", {
+        headers = {
+          ["Content-Type"] = "application/json"
+        },
+        body = {
+          name = "go-auth",
+          route = { id = limited.id },
+          config = {
+            keys = { ["dave-key"] = "dave", ["erin-key"] = "erin" },
+          },
+        }
+      })
+      assert.res_status(201, res)
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+      os.remove(keys_file)
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    it("rejects requests without credentials [golang]", function()
+      local res = proxy_client:get("/auth")
+      local body = assert.res_status(401, res)
+      assert.same({ message = "No API key found in request" }, cjson.decode(body))
+      assert.is_string(res.headers["WWW-Authenticate"])
+    end)
+
+    it("rejects unknown keys and keys of unknown consumers [golang]", function()
+      for _, key in ipairs({ "nobody-key", "ghost-key" }) do
+        local res = proxy_client:get("/auth", { headers = { apikey = key } })
+        local body = assert.res_status(401, res)
+        assert.same({ message = "Invalid authentication credentials" }, cjson.decode(body))
+      end
+    end)
+
+    it("authenticates configured keys from headers and query [golang]", function()
+      local res = proxy_client:get("/auth/request", { headers = { ["x-api-key"] = "alice-key" } })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("alice", body.headers["x-consumer-username"])
+      assert.is_string(body.headers["x-consumer-id"])
+      assert.matches("^key:", body.headers["x-credential-identifier"])
+
+      res = proxy_client:get("/auth/request?apikey=alice-key")
+      body = cjson.decode(assert.res_status(200, res))
+      assert.equal("alice", body.headers["x-consumer-username"])
+    end)
+
+    it("authenticates keys from the keys file [golang]", function()
+      local res = proxy_client:get("/auth/request", { headers = { apikey = "bob-key" } })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("bob", body.headers["x-consumer-username"])
+    end)
+
+    it("authenticates HMAC signatures [golang]", function()
+      local date = ngx.http_time(ngx.time())
+      local res = proxy_client:get("/auth/request", {
+        headers = {
+          ["x-date"] = date,
+          authorization = "hmac alice:" .. sign("alice-secret", "GET", "/auth/request", date),
+        }
+      })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("alice", body.headers["x-consumer-username"])
+      assert.equal("hmac:alice", body.headers["x-credential-identifier"])
+
+      res = proxy_client:get("/auth/request", {
+        headers = {
+          ["x-date"] = date,
+          authorization = "hmac alice:" .. sign("wrong-secret", "GET", "/auth/request", date),
+        }
+      })
+      body = assert.res_status(401, res)
+      assert.same({ message = "Invalid signature" }, cjson.decode(body))
+    end)
+
+    it("sets a consumer acl acts on [golang]", function()
+      local res = proxy_client:get("/auth", { headers = { apikey = "carol-key" } })
+      assert.res_status(403, res)
+    end)
+
+    it("sets a consumer rate-limiting counts by [golang]", function()
+      for i = 1, 3 do
+        local res = proxy_client:get("/limited", { headers = { apikey = "dave-key" } })
+        assert.res_status(200, res)
+        assert.equal(tostring(3 - i), res.headers["X-RateLimit-Remaining-Hour"])
+      end
+
+      local res = proxy_client:get("/limited", { headers = { apikey = "dave-key" } })
+      assert.res_status(429, res)
+
+      -- limits are per consumer
+      res = proxy_client:get("/limited", { headers = { apikey = "erin-key" } })
+      assert.res_status(200, res)
+      assert.equal("2", res.headers["X-RateLimit-Remaining-Hour"])
+    end)
+  end)
+end
@@ -0,0 +1,221 @@
+local helpers = require "spec.helpers"
+local http_mock = require "spec.helpers.http_mock"
+local cjson = require "cjson"
+local pl_file = require "pl.file"
+local pl_stringx = require "pl.stringx"
+
+
+-- the batches written to a file sink, one JSON document per line
+local function read_batches(path)
+  local batches = {}
+  for _, line in ipairs(pl_stringx.splitlines(pl_file.read(path) or "")) do
+    table.insert(batches, cjson.decode(line))
+  end
+  return batches
+end
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("log shipping from external plugins #" .. strategy, function()
+    local proxy_client
+    local collector
+    local collector_port = helpers.get_available_port()
+    local shutdown_file = os.tmpname()
+    local dropping_file = os.tmpname()
+    local invalid_file = os.tmpname()
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local service = assert(bp.services:insert {})
+
+      local configs = {
+        ["/http"] = {
+          sink = "http://127.0.0.1:" .. collector_port .. "/batches",
+          batch_size = 50,
+          flush_ms = 200,
+        },
+        -- only ever flushed when the pluginserver stops
+        ["/shutdown"] = {
+          sink = "file://" .. shutdown_file,
+          batch_size = 1000,
+          flush_ms = 600000,
+        },
+        ["/dropping"] = {
+          sink = "file://" .. dropping_file,
+          batch_size = 1000,
+          flush_ms = 600000,
+          queue_size = 1,
+        },
+        -- would never empty the queue
+        ["/zero-batch"] = {
+          sink = "file://" .. invalid_file,
+          batch_size = 0,
+        },
+        -- can't tick
+        ["/zero-flush"] = {
+          sink = "file://" .. invalid_file,
+          flush_ms = 0,
+        },
+      }
+
+      local routes = {}
+      for path in pairs(configs) do
+        routes[path] = assert(bp.routes:insert({
+          protocols = { "http" },
+          paths = { path },
+          service = service,
+        }))
+      end
+
+      collector = http_mock.new(collector_port)
+      collector:start()
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-logship",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-logship.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-logship -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-logship -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+
+      for path, config in pairs(configs) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-logship",
+            route = { id = routes[path].id },
+            config = config,
+          }
+        })
+        assert.res_status(201, res)
+      end
+
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+      collector:stop()
+      os.remove(shutdown_file)
+      os.remove(dropping_file)
+      os.remove(invalid_file)
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    it("delivers every entry to an HTTP collector under load [golang]", function()
+      local n = 500
+      for i = 1, n do
+        local res = proxy_client:get("/http/" .. i)
+        assert.res_status(200, res)
+      end
+
+      local seen = {}
+      local batches, count = 0, 0
+      helpers.pwait_until(function()
+        for _, log in ipairs(collector:retrieve_mocking_logs()) do
+          assert.equal("/batches", log.req.uri)
+          local batch = cjson.decode(log.req.body)
+          assert.equal(0, batch.dropped)
+          assert.is_true(#batch.entries <= 50)
+          batches = batches + 1
+          for _, entry in ipairs(batch.entries) do
+            seen[entry.request.uri] = true
+            count = count + 1
+          end
+        end
+        assert.equal(n, count)
+      end, 10)
+
+      for i = 1, n do
+        assert.is_true(seen["/http/" .. i], "missing /http/" .. i)
+      end
+      -- flushed by size, not one request at a time
+      assert.is_true(batches >= n / 50 and batches < n)
+    end)
+
+    it("reports entries dropped by a full queue [golang]", function()
+      for _ = 1, 20 do
+        local res = proxy_client:get("/dropping")
+        assert.res_status(200, res)
+      end
+
+      assert.logfile().has.line([[go-logship: queue full, ]], true, 10)
+    end)
+
+    it("ships nothing with a non-positive batch_size or flush_ms [golang]", function()
+      for _, path in ipairs({ "/zero-batch", "/zero-flush" }) do
+        local res = proxy_client:get(path)
+        assert.res_status(200, res)
+      end
+
+      assert.logfile().has.line([[go-logship: batch_size must be positive, got 0]], true, 10)
+      assert.logfile().has.line([[go-logship: flush_ms must be positive, got 0]], true, 10)
+
+      -- the pluginserver lives on for valid configurations
+      local res = proxy_client:get("/http/after-invalid")
+      assert.res_status(200, res)
+      helpers.pwait_until(function()
+        local found = false
+        for _, log in ipairs(collector:retrieve_mocking_logs()) do
+          for _, entry in ipairs(cjson.decode(log.req.body).entries) do
+            found = found or entry.request.uri == "/http/after-invalid"
+          end
+        end
+        assert.is_true(found)
+      end, 10)
+
+      assert.same({}, read_batches(invalid_file))
+    end)
+
+    it("flushes queued entries when the pluginserver stops [golang]", function()
+      for i = 1, 10 do
+        local res = proxy_client:get("/shutdown/" .. i)
+        assert.res_status(200, res)
+      end
+
+      -- nothing is due before the pluginserver stops
+      ngx.sleep(1)
+      assert.same({}, read_batches(shutdown_file))
+
+      proxy_client:close()
+      proxy_client = nil
+      helpers.stop_kong()
+
+      helpers.pwait_until(function()
+        local batches = read_batches(shutdown_file)
+        assert.equal(1, #batches)
+        assert.equal(10, #batches[1].entries)
+        assert.equal(0, batches[1].dropped)
+      end, 10)
+
+      helpers.pwait_until(function()
+        local batches = read_batches(dropping_file)
+        assert.equal(1, #batches)
+        assert.equal(1, #batches[1].entries)
+        assert.equal(19, batches[1].dropped)
+      end, 10)
+    end)
+  end)
+end
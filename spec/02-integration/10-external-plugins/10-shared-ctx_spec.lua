@@ -0,0 +1,150 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+
+
+local LUA_VALUES = require("spec.fixtures.custom_plugins.kong.plugins.ctx-shared-lua.handler").VALUES
+
+
+local GO_KEYS = {
+  "go_string",
+  "go_number",
+  "go_integer",
+  "go_bool",
+  "go_false",
+  "go_array",
+  "go_table",
+  "go_nested",
+  "go_nil",
+  "lua_string",
+}
+
+
+local LUA_KEYS = { "lua_nil", "lua_log", "go_string" }
+for k in pairs(LUA_VALUES) do
+  table.insert(LUA_KEYS, k)
+end
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("kong.ctx.shared between Lua and external plugins #" .. strategy, function()
+    local proxy_client
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }, { "ctx-shared-lua" }))
+
+      local route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/shared" },
+        service = assert(bp.services:insert {}),
+      }))
+
+      bp.plugins:insert({
+        name = "ctx-shared-lua",
+        route = { id = route.id },
+        config = { read = GO_KEYS },
+      })
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,ctx-shared-lua,go-shared-ctx",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-shared-ctx.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-shared-ctx -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-shared-ctx -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+      local res = admin_client:post("/plugins", {
+        headers = {
+          ["Content-Type"] = "application/json"
+        },
+        body = {
+          name = "go-shared-ctx",
+          route = { id = route.id },
+          config = { read = LUA_KEYS },
+        }
+      })
+      assert.res_status(201, res)
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    it("passes Lua values of every type to Go [golang]", function()
+      local res = proxy_client:get("/shared")
+      assert.res_status(200, res)
+      local seen = cjson.decode(assert.response(res).has.header("x-go-shared-read"))
+
+      assert.same({ type = "string", value = "hello from lua" }, seen.lua_string)
+      assert.same({ type = "float64", value = 42.5 }, seen.lua_number)
+      assert.same({ type = "float64", value = 7 }, seen.lua_integer)
+      assert.same({ type = "bool", value = true }, seen.lua_bool)
+      assert.same({ type = "bool", value = false }, seen.lua_false)
+      assert.same({ type = "[]interface {}", value = { "a", "b", "c" } }, seen.lua_array)
+      assert.same({ type = "map[string]interface {}", value = { a = 1, b = "two" } }, seen.lua_table)
+      assert.same("map[string]interface {}", seen.lua_empty.type)
+      assert.same({ type = "map[string]interface {}", value = LUA_VALUES.lua_nested }, seen.lua_nested)
+
+      -- unset keys, and keys set later in the request
+      for _, k in ipairs({ "lua_nil", "lua_log", "go_string" }) do
+        assert.equal("<nil>", seen[k].type)
+        assert.equal(cjson.null, seen[k].value)
+      end
+    end)
+
+    it("passes Go values of every type to Lua [golang]", function()
+      local res = proxy_client:get("/shared")
+      assert.res_status(200, res)
+      local seen = cjson.decode(assert.response(res).has.header("x-lua-shared-read"))
+
+      assert.same({ type = "string", value = "hello from go" }, seen.go_string)
+      assert.same({ type = "number", value = 3.25 }, seen.go_number)
+      assert.same({ type = "number", value = 12 }, seen.go_integer)
+      assert.same({ type = "boolean", value = true }, seen.go_bool)
+      assert.same({ type = "boolean", value = false }, seen.go_false)
+      assert.same({ type = "table", value = { "x", 1, true } }, seen.go_array)
+      assert.same({ type = "table", value = { a = "b", n = 1 } }, seen.go_table)
+      assert.same({
+        type = "table",
+        value = { level1 = { level2 = { "deep", { level3 = true } } } },
+      }, seen.go_nested)
+      assert.same({ type = "nil" }, seen.go_nil)
+    end)
+
+    it("lets Go overwrite values set by Lua [golang]", function()
+      local res = proxy_client:get("/shared")
+      assert.res_status(200, res)
+      local seen = cjson.decode(assert.response(res).has.header("x-lua-shared-read"))
+      assert.same({ type = "string", value = "overwritten by go" }, seen.lua_string)
+    end)
+
+    it("keeps values across phases, up to the log phase [golang]", function()
+      local res = proxy_client:get("/shared")
+      assert.res_status(200, res)
+
+      -- set by Lua in the log phase, and by Go in the access phase
+      assert.logfile().has.line([["lua_log":{"type":"map[string]interface {}","value":{"phase":"log","status":200}}]], true, 10)
+      assert.logfile().has.line([["go_string":{"type":"string","value":"hello from go"}]], true, 10)
+      assert.logfile().has.line([["lua_string":{"type":"string","value":"overwritten by go"}]], true, 10)
+    end)
+  end)
+end
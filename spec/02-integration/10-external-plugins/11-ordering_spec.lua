@@ -0,0 +1,126 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+
+
+local GO_PATH = helpers.external_plugins_path .. "/go"
+
+
+-- variants of the go-order plugin, one pluginserver each
+local VARIANTS = {
+  { name = "go-order-first", priority = 3000, version = "3.0.0" },
+  { name = "go-order-middle", priority = 1000, version = "1.0.0" },
+  { name = "go-order-last", priority = 10, version = "0.0.1" },
+}
+
+
+local function build_variant(variant)
+  assert(helpers.execute(string.format(
+    "cd %s; go build -o %s -ldflags '-X main.priority=%d -X main.version=%s' go-order.go",
+    GO_PATH, variant.name, variant.priority, variant.version)))
+end
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("external plugins ordering #" .. strategy, function()
+    local proxy_client
+    local admin_client
+
+    lazy_setup(function()
+      helpers.build_go_plugins(GO_PATH)
+      for _, variant in ipairs(VARIANTS) do
+        build_variant(variant)
+      end
+
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }, { "order-tracker", "order-tracker-last" }))
+
+      local route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/order" },
+        service = assert(bp.services:insert {}),
+      }))
+
+      bp.plugins:insert({ name = "order-tracker", route = { id = route.id } })
+      bp.plugins:insert({ name = "order-tracker-last", route = { id = route.id } })
+
+      local kong_prefix = helpers.test_conf.prefix
+      local env = {
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,order-tracker,order-tracker-last",
+      }
+
+      local names = {}
+      for _, variant in ipairs(VARIANTS) do
+        local name = variant.name
+        local env_prefix = "pluginserver_" .. name:gsub("-", "_")
+        table.insert(names, name)
+        env.plugins = env.plugins .. "," .. name
+        env[env_prefix .. "_socket"] = kong_prefix .. "/" .. name .. ".socket"
+        env[env_prefix .. "_query_cmd"] = GO_PATH .. "/" .. name .. " -dump -kong-prefix " .. kong_prefix
+        env[env_prefix .. "_start_cmd"] = GO_PATH .. "/" .. name .. " -kong-prefix " .. kong_prefix
+      end
+      env.pluginserver_names = table.concat(names, ",")
+
+      assert(helpers.start_kong(env))
+
+      admin_client = helpers.admin_client()
+      for _, variant in ipairs(VARIANTS) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = variant.name,
+            route = { id = route.id },
+          }
+        })
+        assert.res_status(201, res)
+      end
+    end)
+
+    lazy_teardown(function()
+      if admin_client then
+        admin_client:close()
+      end
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    it("reports the priority and version each variant was built with [golang]", function()
+      local res = admin_client:get("/")
+      local body = assert.res_status(200, res)
+      local available = cjson.decode(body).plugins.available_on_server
+
+      for _, variant in ipairs(VARIANTS) do
+        assert.same({ priority = variant.priority, version = variant.version }, available[variant.name])
+      end
+    end)
+
+    it("runs Go plugins by priority among Lua plugins [golang]", function()
+      local res = proxy_client:get("/order")
+      assert.res_status(200, res)
+      assert.equal(table.concat({
+        "go-order-first",     -- 3000
+        "order-tracker",      -- 2000
+        "go-order-middle",    -- 1000
+        "order-tracker-last", -- 500
+        "go-order-last",      -- 10
+      }, ","), res.headers["x-plugin-order"])
+      -- no PDK call out of its phases along the way
+      assert.logfile().has.no.line("function cannot be called in", true)
+    end)
+  end)
+end
@@ -0,0 +1,153 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("external plugin instances #" .. strategy, function()
+    local proxy_client
+    local admin_client
+    local plugin_id
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local service = assert(bp.services:insert {})
+      local routes = {}
+      for _, path in ipairs({ "/instances", "/other" }) do
+        routes[path] = assert(bp.routes:insert({
+          protocols = { "http" },
+          paths = { path },
+          service = service,
+        }))
+      end
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-instances",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-instances.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-instances -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-instances -kong-prefix " .. kong_prefix,
+      }))
+
+      admin_client = helpers.admin_client()
+
+      for path, tag in pairs({ ["/instances"] = "v1", ["/other"] = "other" }) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-instances",
+            route = { id = routes[path].id },
+            config = { tag = tag },
+          }
+        })
+        local body = cjson.decode(assert.res_status(201, res))
+        if path == "/instances" then
+          plugin_id = body.id
+        end
+      end
+    end)
+
+    lazy_teardown(function()
+      if admin_client then
+        admin_client:close()
+      end
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    local function get(path)
+      local res = proxy_client:get(path)
+      assert.res_status(200, res)
+      return {
+        id = res.headers["x-instance-id"],
+        started = res.headers["x-instance-started"],
+        count = tonumber(res.headers["x-instance-count"]),
+        tag = res.headers["x-instance-tag"],
+        instances = cjson.decode(res.headers["x-instances"]),
+      }
+    end
+
+    it("keeps one instance per plugin configuration [golang]", function()
+      local first = get("/instances")
+      assert.equal("v1", first.tag)
+      assert.is_string(first.id)
+      assert.is_string(first.started)
+
+      for i = 1, 4 do
+        local seen = get("/instances")
+        assert.equal(first.id, seen.id)
+        assert.equal(first.started, seen.started)
+        assert.equal(first.count + i, seen.count)
+      end
+
+      -- the other route has its own instance and count
+      local other = get("/other")
+      assert.equal("other", other.tag)
+      assert.not_equal(first.id, other.id)
+      assert.equal(1, other.count)
+      assert.equal(first.count + 4, other.instances[first.id])
+    end)
+
+    it("starts a new instance when the configuration changes [golang]", function()
+      local old = get("/instances")
+
+      local res = admin_client:patch("/plugins/" .. plugin_id, {
+        headers = {
+          ["Content-Type"] = "application/json"
+        },
+        body = {
+          config = { tag = "v2" },
+        }
+      })
+      assert.res_status(200, res)
+
+      helpers.pwait_until(function()
+        local client = helpers.proxy_client()
+        local r = client:get("/instances")
+        assert.res_status(200, r)
+        assert.equal("v2", r.headers["x-instance-tag"])
+        client:close()
+      end, 10)
+
+      local new = get("/instances")
+      assert.equal("v2", new.tag)
+      assert.not_equal(old.id, new.id)
+      assert.is_true(new.started > old.started)
+
+      -- the old instance isn't called anymore; requests made while the
+      -- change propagated may still have reached it
+      local frozen = new.instances[old.id]
+      assert.is_true(frozen >= old.count)
+      for i = 1, 5 do
+        local seen = get("/instances")
+        assert.equal(new.id, seen.id)
+        assert.equal(new.count + i, seen.count)
+        assert.equal(frozen, seen.instances[old.id])
+      end
+
+      -- nor does the change touch other configurations
+      local other = get("/other")
+      assert.equal("other", other.tag)
+      assert.not_equal(new.id, other.id)
+    end)
+  end)
+end
@@ -0,0 +1,304 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+
+
+local SCHEMA = {
+  type = "object",
+  required = { "name", "items" },
+  additionalProperties = false,
+  properties = {
+    name = { type = "string", minLength = 1, maxLength = 20, pattern = "^[a-z]+$" },
+    age = { type = "integer", minimum = 0, exclusiveMaximum = 150 },
+    role = { enum = { "admin", "user" } },
+    items = {
+      type = "array",
+      items = {
+        type = "object",
+        required = { "id" },
+        properties = {
+          id = { type = "integer" },
+          tags = { type = "array", items = { type = "string" } },
+        },
+      },
+    },
+  },
+}
+
+
+-- keywords beyond the plain checks of SCHEMA
+local KEYWORDS_SCHEMA = [[{
+  "$defs": { "id": { "type": "integer", "minimum": 1 } },
+  "type": "object",
+  "properties": {
+    "id": { "$ref": "#/$defs/id" },
+    "email": { "type": "string", "format": "email" },
+    "contact": { "oneOf": [ { "required": [ "phone" ] }, { "required": [ "email" ] } ] },
+    "meta": {
+      "type": "object",
+      "patternProperties": { "^x-": { "type": "string" } },
+      "additionalProperties": { "type": "integer" }
+    },
+    "price": { "anyOf": [ { "type": "number" }, { "type": "string", "pattern": "^[0-9]+$" } ] },
+    "code": { "allOf": [ { "type": "string" }, { "minLength": 2 } ], "not": { "const": "xx" } },
+    "pair": { "enum": [ [ 1, 2 ], { "a": 1, "b": 2 } ] }
+  }
+}]]
+
+
+local function paths(errors)
+  local out = {}
+  for i, e in ipairs(errors) do
+    out[i] = e.path
+  end
+  return out
+end
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("body validation by external plugins #" .. strategy, function()
+    local proxy_client
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local service = assert(bp.services:insert {})
+      local routes = {}
+      for _, path in ipairs({ "/validated", "/keywords", "/const", "/broken-schema", "/remote-ref" }) do
+        routes[path] = assert(bp.routes:insert({
+          protocols = { "http" },
+          paths = { path },
+          service = service,
+        }))
+      end
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-validator",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-validator.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-validator -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-validator -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+      local schemas = {
+        ["/validated"] = cjson.encode(SCHEMA),
+        ["/keywords"] = KEYWORDS_SCHEMA,
+        ["/const"] = [[{"properties": {"parent": {"const": null}, "kind": {"const": 1}}}]],
+        ["/broken-schema"] = [[{"type": "strange"}]],
+        ["/remote-ref"] = [[{"$ref": "http://127.0.0.1/schema.json"}]],
+      }
+      for path, schema in pairs(schemas) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-validator",
+            route = { id = routes[path].id },
+            config = { schema = schema },
+          }
+        })
+        assert.res_status(201, res)
+      end
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    local function post(body, content_type)
+      return proxy_client:post("/validated/request", {
+        headers = { ["Content-Type"] = content_type or "application/json" },
+        body = body,
+      })
+    end
+
+    it("proxies valid bodies [golang]", function()
+      local res = post({ name = "alice", age = 30, role = "admin", items = { { id = 1, tags = { "a" } } } })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("true", body.headers["x-body-validated"])
+      assert.equal("alice", body.post_data.params.name)
+    end)
+
+    it("lists every failing path [golang]", function()
+      local res = post({
+        name = "Bob1",
+        age = 200,
+        role = "root",
+        extra = true,
+        items = { { id = "x" }, { tags = { 1 } } },
+      })
+      local body = cjson.decode(assert.res_status(400, res))
+      assert.equal("application/json", res.headers["Content-Type"])
+      assert.equal("request body does not match the schema", body.message)
+      -- properties missing or not allowed are errors of their object
+      assert.same({
+        "/",
+        "/age",
+        "/items/0/id",
+        "/items/1",
+        "/items/1/tags/0",
+        "/name",
+        "/role",
+      }, paths(body.errors))
+      assert.same({ path = "/", error = "additionalProperties 'extra' not allowed" }, body.errors[1])
+      assert.same({ path = "/age", error = "must be < 150 but found 200" }, body.errors[2])
+      assert.same({ path = "/items/0/id", error = "expected integer, but got string" }, body.errors[3])
+      assert.same({ path = "/items/1", error = "missing properties: 'id'" }, body.errors[4])
+    end)
+
+    it("reports missing required properties [golang]", function()
+      local res = post("{}")
+      local body = cjson.decode(assert.res_status(400, res))
+      assert.same({ { path = "/", error = "missing properties: 'name', 'items'" } }, body.errors)
+    end)
+
+    it("rejects bodies that aren't JSON [golang]", function()
+      local res = post("name=alice", "application/x-www-form-urlencoded")
+      local body = cjson.decode(assert.res_status(415, res))
+      assert.equal("request body must be JSON", body.message)
+
+      res = post([[{"name": "alice",]])
+      body = cjson.decode(assert.res_status(400, res))
+      assert.equal("request body is not valid JSON", body.message)
+      assert.equal("/", body.errors[1].path)
+
+      res = post([[{"name": "alice", "items": []} {}]])
+      body = cjson.decode(assert.res_status(400, res))
+      assert.equal("request body is not valid JSON", body.message)
+    end)
+
+    it("validates large bodies [golang]", function()
+      local items = {}
+      for i = 1, 20000 do
+        items[i] = { id = i, tags = { "some", "tags" } }
+      end
+      local large = { name = "alice", items = items }
+      assert.is_true(#cjson.encode(large) > 512 * 1024)
+
+      local res = post(large)
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("true", body.headers["x-body-validated"])
+
+      items[15001].id = "not an id"
+      res = post(large)
+      body = cjson.decode(assert.res_status(400, res))
+      assert.same({ { path = "/items/15000/id", error = "expected integer, but got string" } }, body.errors)
+    end)
+
+    it("lets requests without a body through [golang]", function()
+      for _, method in ipairs({ "GET", "DELETE" }) do
+        local res = proxy_client:send({ method = method, path = "/validated/request" })
+        local body = cjson.decode(assert.res_status(200, res))
+        assert.is_nil(body.headers["x-body-validated"])
+      end
+
+      local res = proxy_client:send({ method = "HEAD", path = "/validated/request" })
+      assert.res_status(200, res)
+
+      -- a content type says there's a body to validate
+      res = proxy_client:get("/validated/request", {
+        headers = { ["Content-Type"] = "application/json" },
+      })
+      local body = cjson.decode(assert.res_status(400, res))
+      assert.equal("request body is not valid JSON", body.message)
+    end)
+
+    it("checks constants, null included [golang]", function()
+      local res = proxy_client:post("/const/request", {
+        headers = { ["Content-Type"] = "application/json" },
+        body = [[{"parent": null, "kind": 1.0}]],
+      })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("true", body.headers["x-body-validated"])
+
+      res = proxy_client:post("/const/request", {
+        headers = { ["Content-Type"] = "application/json" },
+        body = [[{"parent": 0, "kind": 2}]],
+      })
+      body = cjson.decode(assert.res_status(400, res))
+      assert.same({ "/kind", "/parent" }, paths(body.errors))
+    end)
+
+    it("supports references, combinators, formats and schema-valued keywords [golang]", function()
+      local res = proxy_client:post("/keywords/request", {
+        headers = { ["Content-Type"] = "application/json" },
+        body = [[{
+          "id": 3,
+          "email": "alice@example.com",
+          "contact": { "phone": "555" },
+          "meta": { "x-team": "blue", "size": 1 },
+          "price": "12",
+          "code": "ab",
+          "pair": { "b": 2, "a": 1.0 }
+        }]],
+      })
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("true", body.headers["x-body-validated"])
+
+      res = proxy_client:post("/keywords/request", {
+        headers = { ["Content-Type"] = "application/json" },
+        body = [[{
+          "id": 0,
+          "email": "nope",
+          "contact": { "phone": "555", "email": "alice@example.com" },
+          "meta": { "x-team": 1, "size": "big" },
+          "price": true,
+          "code": "xx",
+          "pair": [ 2, 1 ]
+        }]],
+      })
+      body = cjson.decode(assert.res_status(400, res))
+      assert.same({
+        "/code",        -- not
+        "/contact",     -- oneOf, both match
+        "/email",       -- format
+        "/id",          -- $ref
+        "/meta/size",   -- additionalProperties schema
+        "/meta/x-team", -- patternProperties
+        "/pair",        -- enum, by value
+        "/price",       -- anyOf, each alternative
+        "/price",
+      }, paths(body.errors))
+    end)
+
+    it("fails closed on invalid schemas [golang]", function()
+      local res = proxy_client:post("/broken-schema", {
+        headers = { ["Content-Type"] = "application/json" },
+        body = { name = "alice" },
+      })
+      local body = cjson.decode(assert.res_status(500, res))
+      assert.equal("invalid schema", body.message)
+      assert.logfile().has.line([[go-validator: invalid schema: ]], true, 10)
+      assert.logfile().has.line([['/type' does not validate]], true, 10)
+
+      -- nothing is fetched to resolve references
+      res = proxy_client:post("/remote-ref", {
+        headers = { ["Content-Type"] = "application/json" },
+        body = { name = "alice" },
+      })
+      body = cjson.decode(assert.res_status(500, res))
+      assert.equal("invalid schema", body.message)
+      assert.logfile().has.line([[only references within the schema are supported]], true, 10)
+    end)
+  end)
+end
@@ -0,0 +1,177 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+local pl_file = require "pl.file"
+local pl_path = require "pl.path"
+
+
+-- the target answers differently when it sees these metadata
+local SCENARIO = {
+  rules = {
+    {
+      method = "/targetservice.Bouncer/SayHello",
+      match = { metadata = { ["x-secret"] = "s3cr3t" } },
+      response = { message = { reply = "secret leaked" } },
+    },
+    {
+      method = "/targetservice.Bouncer/SayHello",
+      match = { metadata = { ["x-go-grpc"] = "from-go" } },
+      response = {
+        headers = { ["x-target-saw"] = "x-go-grpc" },
+        message = { reply = "metadata seen by target" },
+      },
+    },
+  },
+}
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("external plugins on gRPC routes #" .. strategy, function()
+    local scenario_file = os.tmpname()
+    local grpc_client
+
+    lazy_setup(function()
+      assert(pl_file.write(scenario_file, cjson.encode(SCENARIO)))
+      assert(helpers.start_grpc_target({ scenario = scenario_file }))
+
+      -- start_grpc_target takes long time, the db socket might already
+      -- be timeout, so we close it to avoid `db:init_connector` failing
+      -- in `helpers.get_db_utils`
+      helpers.db:connect()
+      helpers.db:close()
+
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local service = assert(bp.services:insert {
+        protocol = "grpc",
+        host = "127.0.0.1",
+        port = helpers.get_grpc_target_port(),
+      })
+
+      local configs = {
+        ["metadata.test"] = {
+          read = { "x-client" },
+          set = { ["x-go-grpc"] = "from-go", ["x-also"] = "set" },
+          clear = { "x-secret" },
+        },
+        ["reject.test"] = {
+          reject = {
+            when = { ["x-client"] = "banned" },
+            grpc_status = 8,
+            message = "go-grpc: slow down",
+          },
+        },
+        ["reject-mapped.test"] = {
+          reject = {
+            when = { ["x-client"] = "banned" },
+            http_status = 401,
+          },
+        },
+      }
+
+      local routes = {}
+      for host in pairs(configs) do
+        routes[host] = assert(bp.routes:insert({
+          protocols = { "grpc" },
+          hosts = { host },
+          service = service,
+        }))
+      end
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-grpc",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-grpc.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-grpc -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-grpc -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+      for host, config in pairs(configs) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-grpc",
+            route = { id = routes[host].id },
+            config = config,
+          }
+        })
+        assert.res_status(201, res)
+      end
+      admin_client:close()
+
+      grpc_client = helpers.proxy_client_grpc()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+      helpers.stop_grpc_target()
+      os.remove(scenario_file)
+    end)
+
+    -- calls SayHello through Kong, with one metadata entry at most
+    local function say_hello(authority, header)
+      local proto = helpers.get_grpc_target_proto_path()
+      return grpc_client({
+        service = "targetservice.Bouncer.SayHello",
+        body = { greeting = "world" },
+        opts = {
+          ["-import-path"] = pl_path.dirname(proto),
+          ["-proto"] = pl_path.basename(proto),
+          ["-authority"] = authority,
+          ["-H"] = header and string.format("'%s'", header),
+          ["-v"] = true,
+        },
+      })
+    end
+
+    it("reads the call and its metadata through the PDK [golang]", function()
+      local ok, out = say_hello("metadata.test", "x-client: abc")
+      assert.truthy(ok, out)
+      assert.matches("x-go-grpc-seen: method=POST path=/targetservice.Bouncer/SayHello http=2 "
+                     .. "content-type=application/grpc x-client=abc", out, nil, true)
+    end)
+
+    it("sets request metadata for the upstream [golang]", function()
+      local ok, out = say_hello("metadata.test")
+      assert.truthy(ok, out)
+      assert.matches("x-go-grpc-set: x-also,x-go-grpc", out, nil, true)
+      assert.matches("x-target-saw: x-go-grpc", out, nil, true)
+      assert.matches([["reply": "metadata seen by target"]], out, nil, true)
+    end)
+
+    it("clears request metadata before the upstream [golang]", function()
+      local ok, out = say_hello("metadata.test", "x-secret: s3cr3t")
+      assert.truthy(ok, out)
+      assert.not_matches("secret leaked", out, nil, true)
+      assert.matches([["reply": "metadata seen by target"]], out, nil, true)
+    end)
+
+    it("rejects calls with a grpc-status [golang]", function()
+      local ok, out = say_hello("reject.test", "x-client: banned")
+      assert.falsy(ok)
+      assert.matches("Code: ResourceExhausted", out, nil, true)
+      assert.matches("Message: go-grpc: slow down", out, nil, true)
+
+      ok, out = say_hello("reject.test", "x-client: welcome")
+      assert.truthy(ok, out)
+      assert.matches([["reply": "hello world"]], out, nil, true)
+    end)
+
+    it("rejects calls with an HTTP status Kong maps to a grpc-status [golang]", function()
+      local ok, out = say_hello("reject-mapped.test", "x-client: banned")
+      assert.falsy(ok)
+      assert.matches("Code: Unauthenticated", out, nil, true)
+      assert.matches("Message: go-grpc: rejected", out, nil, true)
+    end)
+  end)
+end
@@ -0,0 +1,175 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+local pl_file = require "pl.file"
+
+
+local ITERATIONS = 50
+
+
+-- one profile per phase, the last one written wins
+local function read_dump(path)
+  local content = pl_file.read(path)
+  if not content then
+    return {}
+  end
+
+  local profiles = {}
+  for line in content:gmatch("[^\n]+") do
+    local p = cjson.decode(line)
+    profiles[p.phase] = p
+  end
+  return profiles
+end
+
+
+local function assert_histogram(name, h)
+  assert.equal(ITERATIONS, h.count, name)
+  assert.equal(0, h.errors, name)
+  assert.is_true(h.min_us > 0, name)
+  assert.is_true(h.min_us <= h.p50_us, name)
+  assert.is_true(h.p50_us <= h.p99_us, name)
+  assert.is_true(h.p99_us <= h.max_us, name)
+  assert.is_true(h.min_us <= h.mean_us and h.mean_us <= h.max_us, name)
+end
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("PDK call profiling by external plugins #" .. strategy, function()
+    local dump_file = os.tmpname()
+    local proxy_client
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local route = assert(bp.routes:insert({
+        protocols = { "http" },
+        paths = { "/profiled" },
+        service = assert(bp.services:insert {}),
+      }))
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-profiler",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-profiler.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-profiler -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-profiler -kong-prefix " .. kong_prefix,
+      }))
+
+      local admin_client = helpers.admin_client()
+      -- rewrite only runs for global plugins; the route one handles the
+      -- later phases
+      for _, scope in ipairs({ { route = { id = route.id } }, {} }) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = "go-profiler",
+            route = scope.route,
+            config = {
+              iterations = ITERATIONS,
+              dump_file = dump_file,
+            },
+          }
+        })
+        assert.res_status(201, res)
+      end
+      admin_client:close()
+    end)
+
+    lazy_teardown(function()
+      helpers.stop_kong()
+      os.remove(dump_file)
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    it("reports access phase latencies in a header [golang]", function()
+      local res = proxy_client:get("/profiled/request")
+      local body = cjson.decode(assert.res_status(200, res))
+      assert.equal("true", res.headers["x-pdk-profiled"])
+      assert.equal("true", body.headers["x-pdk-profiled"])
+      -- from the other phases
+      assert.equal("true", body.headers["x-pdk-profiled-rewrite"])
+      assert.equal("true", res.headers["x-pdk-profiled-response"])
+
+      local profile = cjson.decode(assert.response(res).has.header("x-pdk-profile"))
+      assert.equal("access", profile.phase)
+      assert.equal(ITERATIONS, profile.iterations)
+
+      for _, name in ipairs({
+        "kong.request.get_method",
+        "kong.request.get_header",
+        "kong.request.get_headers",
+        "kong.client.get_ip",
+        "kong.nginx.get_var",
+        "kong.ctx.shared.set",
+        "kong.ctx.shared.get",
+        "kong.node.get_id",
+        "kong.response.set_header",
+        "kong.service.request.set_header",
+      }) do
+        assert.is_table(profile.calls[name], name)
+        assert_histogram(name, profile.calls[name])
+        assert.is_nil(profile.calls[name].buckets, name)
+      end
+    end)
+
+    it("dumps histograms of every phase to a file [golang]", function()
+      local res = proxy_client:get("/profiled/request")
+      assert.res_status(200, res)
+
+      local profiles
+      helpers.pwait_until(function()
+        profiles = read_dump(dump_file)
+        assert.is_table(profiles.rewrite)
+        assert.is_table(profiles.access)
+        assert.is_table(profiles.response)
+        assert.is_table(profiles.log)
+      end, 10)
+
+      assert.is_table(profiles.rewrite.calls["kong.request.get_header"])
+      assert.is_table(profiles.rewrite.calls["kong.service.request.set_header"])
+      for _, name in ipairs({
+        "kong.service.response.get_status",
+        "kong.service.response.get_header",
+        "kong.response.get_status",
+        "kong.response.set_header",
+      }) do
+        assert.is_table(profiles.response.calls[name], name)
+      end
+      assert.is_table(profiles.log.calls["kong.log.serialize"])
+      assert.is_table(profiles.log.calls["kong.response.get_status"])
+
+      for _, p in pairs(profiles) do
+        assert.equal(ITERATIONS, p.iterations)
+        for name, h in pairs(p.calls) do
+          assert_histogram(name, h)
+
+          local total = 0
+          for _, n in pairs(h.buckets) do
+            total = total + n
+          end
+          assert.equal(ITERATIONS, total, name)
+          assert.is_number(h.buckets.le_inf, name)
+        end
+      end
+    end)
+  end)
+end
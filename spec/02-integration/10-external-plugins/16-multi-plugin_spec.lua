@@ -0,0 +1,159 @@
+local helpers = require "spec.helpers"
+local cjson = require "cjson"
+
+
+for _, strategy in helpers.each_strategy() do
+  describe("pluginservers hosting several plugins #" .. strategy, function()
+    local proxy_client
+    local admin_client
+
+    lazy_setup(function()
+      local bp = assert(helpers.get_db_utils(strategy, {
+        "services",
+        "routes",
+        "plugins",
+      }))
+
+      local service = assert(bp.services:insert {})
+      local routes = {}
+      for _, path in ipairs({ "/a", "/a-other", "/b", "/both" }) do
+        routes[path] = assert(bp.routes:insert({
+          protocols = { "http" },
+          paths = { path },
+          service = service,
+        }))
+      end
+
+      local kong_prefix = helpers.test_conf.prefix
+
+      assert(helpers.start_kong({
+        nginx_conf = "spec/fixtures/custom_nginx.template",
+        database = strategy,
+        plugins = "bundled,go-multi-a,go-multi-b",
+        pluginserver_names = "test-go",
+        pluginserver_test_go_socket = kong_prefix .. "/go-multi.socket",
+        pluginserver_test_go_query_cmd = helpers.external_plugins_path .. "/go/go-multi -dump -kong-prefix " .. kong_prefix,
+        pluginserver_test_go_start_cmd = helpers.external_plugins_path .. "/go/go-multi -kong-prefix " .. kong_prefix,
+      }))
+
+      admin_client = helpers.admin_client()
+
+      for _, plugin in ipairs({
+        { "/a", "go-multi-a", "a1" },
+        { "/a-other", "go-multi-a", "a2" },
+        { "/b", "go-multi-b", "b1" },
+        { "/both", "go-multi-a", "both-a" },
+        { "/both", "go-multi-b", "both-b" },
+      }) do
+        local res = admin_client:post("/plugins", {
+          headers = {
+            ["Content-Type"] = "application/json"
+          },
+          body = {
+            name = plugin[2],
+            route = { id = routes[plugin[1]].id },
+            config = { tag = plugin[3] },
+          }
+        })
+        assert.res_status(201, res)
+      end
+    end)
+
+    lazy_teardown(function()
+      if admin_client then
+        admin_client:close()
+      end
+      helpers.stop_kong()
+    end)
+
+    before_each(function()
+      proxy_client = helpers.proxy_client()
+    end)
+
+    after_each(function()
+      if proxy_client then
+        proxy_client:close()
+      end
+    end)
+
+    -- what each plugin reported, by plugin name
+    local function get(path)
+      local res = proxy_client:get(path)
+      assert.res_status(200, res)
+
+      local seen = {}
+      for _, name in ipairs({ "go-multi-a", "go-multi-b" }) do
+        local tag = res.headers["x-" .. name .. "-tag"]
+        if tag then
+          local instance = res.headers["x-" .. name .. "-instance"]
+          seen[name] = {
+            tag = tag,
+            instance = instance,
+            pid = instance:match("^(%d+)-"),
+            count = tonumber(res.headers["x-" .. name .. "-count"]),
+          }
+        end
+      end
+      return seen
+    end
+
+    it("loads every plugin the pluginserver lists [golang]", function()
+      local res = admin_client:get("/plugins/enabled")
+      local enabled = cjson.decode(assert.res_status(200, res)).enabled_plugins
+      assert.contains("go-multi-a", enabled)
+      assert.contains("go-multi-b", enabled)
+
+      for _, name in ipairs({ "go-multi-a", "go-multi-b" }) do
+        res = admin_client:get("/schemas/plugins/" .. name)
+        assert.res_status(200, res)
+      end
+    end)
+
+    it("routes calls to the plugin each configuration is for [golang]", function()
+      local seen = get("/a")
+      assert.equal("a1", seen["go-multi-a"].tag)
+      assert.is_nil(seen["go-multi-b"])
+
+      seen = get("/b")
+      assert.equal("b1", seen["go-multi-b"].tag)
+      assert.is_nil(seen["go-multi-a"])
+
+      -- both plugins on one request, through the same process
+      seen = get("/both")
+      assert.equal("both-a", seen["go-multi-a"].tag)
+      assert.equal("both-b", seen["go-multi-b"].tag)
+      assert.equal(seen["go-multi-a"].pid, seen["go-multi-b"].pid)
+    end)
+
+    it("keeps the instances of each plugin apart [golang]", function()
+      local a = get("/a")["go-multi-a"]
+      local other = get("/a-other")["go-multi-a"]
+      local b = get("/b")["go-multi-b"]
+      local both = get("/both")
+
+      -- one process, an instance for each configuration of either plugin
+      local instances = {}
+      for _, seen in ipairs({ a, other, b, both["go-multi-a"], both["go-multi-b"] }) do
+        assert.equal(a.pid, seen.pid)
+        assert.is_nil(instances[seen.instance], seen.instance .. " seen twice")
+        instances[seen.instance] = true
+      end
+
+      -- calls to one instance leave the others alone
+      for i = 1, 3 do
+        local seen = get("/a")["go-multi-a"]
+        assert.equal(a.instance, seen.instance)
+        assert.equal(a.count + i, seen.count)
+      end
+
+      local seen = get("/a-other")["go-multi-a"]
+      assert.equal(other.instance, seen.instance)
+      assert.equal("a2", seen.tag)
+      assert.equal(other.count + 1, seen.count)
+
+      seen = get("/b")["go-multi-b"]
+      assert.equal(b.instance, seen.instance)
+      assert.equal(b.count + 1, seen.count)
+    end)
+  end)
+end
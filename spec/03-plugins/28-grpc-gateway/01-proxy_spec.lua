@@ -1,14 +1,64 @@
 local cjson = require "cjson"
 local helpers = require "spec.helpers"
+local pl_file = require "pl.file"
+
+
+-- response metadata for the transcoders to turn into headers
+local SCENARIO = {
+  rules = {
+    {
+      method = "/targetservice.Bouncer/SayHello",
+      match = { request = { greeting = "metadata" } },
+      response = {
+        headers = { ["x-from"] = "scenario" },
+        message = { reply = "hello metadata" },
+      },
+    },
+  },
+}
+
+
+-- not part of a transcoding: hop-by-hop, per server and per response
+local IGNORED_HEADERS = {
+  ["connection"] = true,
+  ["keep-alive"] = true,
+  ["proxy-authenticate"] = true,
+  ["proxy-authorization"] = true,
+  ["te"] = true,
+  ["trailer"] = true,
+  ["transfer-encoding"] = true,
+  ["upgrade"] = true,
+  ["date"] = true,
+  ["server"] = true,
+  ["via"] = true,
+  -- both bodies are compared decoded, protojson randomizes its whitespace
+  ["content-length"] = true,
+  -- CORS support of the plugin, which the reference doesn't have
+  ["access-control-allow-origin"] = true,
+}
+
+
+local function transcoding_headers(res)
+  local headers = {}
+  for name, value in pairs(res.headers) do
+    name = name:lower()
+    if not IGNORED_HEADERS[name] and not name:find("^x%-kong%-") then
+      headers[name] = value
+    end
+  end
+  return headers
+end
 
 for _, strategy in helpers.each_strategy() do
 
   describe("gRPC-Gateway [#" .. strategy .. "]", function()
     local proxy_client
+    local scenario_file = os.tmpname()
 
 
     lazy_setup(function()
-      assert(helpers.start_grpc_target())
+      assert(pl_file.write(scenario_file, cjson.encode(SCENARIO)))
+      assert(helpers.start_grpc_target({ scenario = scenario_file }))
 
       -- start_grpc_target takes long time, the db socket might already
       -- be timeout, so we close it to avoid `db:init_connector` failing
@@ -44,7 +94,7 @@ for _, strategy in helpers.each_strategy() do
         route = route1,
         name = "grpc-gateway",
         config = {
-          proto = "./spec/fixtures/grpc/targetservice.proto",
+          proto = helpers.get_grpc_target_proto_path(),
         },
       })
 
@@ -101,6 +151,7 @@ for _, strategy in helpers.each_strategy() do
     lazy_teardown(function()
       helpers.stop_kong()
       helpers.stop_grpc_target()
+      os.remove(scenario_file)
     end)
 
     test("#new Sets 'TE: trailers'", function()
@@ -294,6 +345,59 @@ for _, strategy in helpers.each_strategy() do
       assert.same(res:read_body(),"failed to encode payload")
     end)
 
+    describe("matches the reference transcoder", function()
+      -- the target serves the same google.api.http mappings itself; every
+      -- difference with Kong's transcoding fails here
+      local function compare(method, path, body)
+        local reference_client = helpers.http_client("127.0.0.1", helpers.get_grpc_target_rest_port(), 1000)
+        local opts = {
+          method = method,
+          path = path,
+          headers = { ["Content-Type"] = "application/json" },
+          body = body,
+        }
+
+        local expected = assert(reference_client:send(opts))
+        local expected_body = expected:read_body()
+        reference_client:close()
+
+        local res = assert(proxy_client:send(opts))
+        local res_body = res:read_body()
+
+        assert.equal(expected.status, res.status)
+        assert.same(transcoding_headers(expected), transcoding_headers(res))
+        assert.same(cjson.decode(expected_body), cjson.decode(res_body))
+
+        return res
+      end
+
+      test("path variables", function()
+        compare("GET", "/v1/messages/john_doe")
+      end)
+
+      test("query parameters", function()
+        compare("GET", "/v1/messages/legacy/john_doe?boolean_test=true")
+      end)
+
+      test("well-known types in the body", function()
+        compare("POST", "/bounce", {
+          message = "hi",
+          when = "2021-01-01T00:00:00Z",
+          now = "2021-01-01T00:05:15Z",
+        })
+      end)
+
+      test("repeated fields in the body", function()
+        compare("POST", "/v1/echo", { array = { "a", "b" }, nullable = "ahaha" })
+      end)
+
+      test("response metadata", function()
+        local res = compare("GET", "/v1/messages/metadata")
+        assert.equal("scenario", res.headers["x-from"])
+        assert.matches("application/json", res.headers["Content-Type"], nil, true)
+      end)
+    end)
+
     describe("regression", function()
       test("empty array in json #10801", function()
         local req_body = { array = {}, nullable = "ahaha" }
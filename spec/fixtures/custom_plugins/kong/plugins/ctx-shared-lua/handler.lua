@@ -0,0 +1,67 @@
+-- Lua side of the kong.ctx.shared round trip with the go-shared-ctx
+-- external plugin: runs before it in every phase, puts Lua values in
+-- the shared context, and reports the values the Go plugin put there.
+local cjson = require "cjson.safe"
+
+
+local kong = kong
+local type = type
+local pairs = pairs
+local ipairs = ipairs
+
+
+local CtxSharedLuaHandler = {
+  VERSION = "0.1-t",
+  PRIORITY = 1000,
+}
+
+
+-- what the Go plugin is expected to read back
+local VALUES = {
+  lua_string = "hello from lua",
+  lua_number = 42.5,
+  lua_integer = 7,
+  lua_bool = true,
+  lua_false = false,
+  lua_array = { "a", "b", "c" },
+  lua_table = { a = 1, b = "two" },
+  lua_empty = {},
+  lua_nested = { level1 = { level2 = { "deep", { level3 = true } } } },
+}
+
+
+local function describe(value)
+  return { type = type(value), value = value }
+end
+
+
+function CtxSharedLuaHandler:access(conf)
+  local shared = kong.ctx.shared
+  for k, v in pairs(VALUES) do
+    shared[k] = v
+  end
+  -- never set, read by Go as null
+  shared.lua_nil = nil
+end
+
+
+function CtxSharedLuaHandler:header_filter(conf)
+  local shared = kong.ctx.shared
+  local seen = {}
+  for _, k in ipairs(conf.read) do
+    seen[k] = describe(shared[k])
+  end
+  kong.response.set_header("x-lua-shared-read", cjson.encode(seen))
+end
+
+
+function CtxSharedLuaHandler:log(conf)
+  -- for the log phase of the Go plugin, which runs after this one
+  kong.ctx.shared.lua_log = { phase = "log", status = kong.response.get_status() }
+end
+
+
+CtxSharedLuaHandler.VALUES = VALUES
+
+
+return CtxSharedLuaHandler
@@ -0,0 +1,14 @@
+return {
+  name = "ctx-shared-lua",
+  fields = {
+    {
+      config = {
+        type = "record",
+        fields = {
+          -- the kong.ctx.shared keys reported in header_filter
+          { read = { type = "array", elements = { type = "string" }, default = {} } },
+        },
+      },
+    },
+  },
+}
@@ -0,0 +1,15 @@
+local OrderTrackerHandler = require "spec.fixtures.custom_plugins.kong.plugins.order-tracker.handler"
+
+
+local OrderTrackerLastHandler = {
+  VERSION = "0.1-t",
+  PRIORITY = 500,
+  _name = "order-tracker-last",
+}
+
+
+OrderTrackerLastHandler.access = OrderTrackerHandler.access
+OrderTrackerLastHandler.header_filter = OrderTrackerHandler.header_filter
+
+
+return OrderTrackerLastHandler
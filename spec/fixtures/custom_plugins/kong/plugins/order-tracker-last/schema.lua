@@ -0,0 +1,11 @@
+return {
+  name = "order-tracker-last",
+  fields = {
+    {
+      config = {
+        type = "record",
+        fields = {},
+      },
+    },
+  },
+}
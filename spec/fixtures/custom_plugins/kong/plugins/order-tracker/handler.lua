@@ -0,0 +1,25 @@
+local kong = kong
+
+
+local OrderTrackerHandler = {
+  VERSION = "0.1-t",
+  PRIORITY = 2000,
+  _name = "order-tracker",
+}
+
+
+-- appends the plugin name to x-plugin-order, like the go-order external
+-- plugin; the order is kept in kong.ctx.shared.plugin_order, shared with
+-- it, until there is a response to set the header on
+function OrderTrackerHandler:access(conf)
+  local order = kong.ctx.shared.plugin_order
+  kong.ctx.shared.plugin_order = order and order .. "," .. self._name or self._name
+end
+
+
+function OrderTrackerHandler:header_filter(conf)
+  kong.response.set_header("x-plugin-order", kong.ctx.shared.plugin_order)
+end
+
+
+return OrderTrackerHandler
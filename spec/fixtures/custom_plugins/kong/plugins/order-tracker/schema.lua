@@ -0,0 +1,11 @@
+return {
+  name = "order-tracker",
+  fields = {
+    {
+      config = {
+        type = "record",
+        fields = {},
+      },
+    },
+  },
+}
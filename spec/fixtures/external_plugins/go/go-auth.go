@@ -0,0 +1,174 @@
+/*
+An authentication plugin in Go,
+which checks an API key or an HMAC signature and sets the consumer,
+for plugins like acl and rate-limiting to act on.
+
+API keys are read from the key_names headers or query arguments, and
+mapped to consumer usernames by keys, or by the JSON object in keys_file.
+The file is read on every request, so specs can change it on the fly.
+
+HMAC requests carry
+
+	Authorization: hmac USERNAME:SIGNATURE
+
+where SIGNATURE is the base64 HMAC-SHA256, keyed with the secret of
+USERNAME in hmac_secrets, of "METHOD PATH\nX-DATE" with X-DATE the value
+of the x-date header.
+*/
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/client"
+	"github.com/Kong/go-pdk/entities"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	KeyNames    []string          `json:"key_names"`
+	Keys        map[string]string `json:"keys"`
+	KeysFile    string            `json:"keys_file"`
+	HmacSecrets map[string]string `json:"hmac_secrets"`
+}
+
+func New() interface{} {
+	return &Config{KeyNames: []string{"apikey"}}
+}
+
+func main() {
+	// before acl (950) and rate-limiting (910), like key-auth
+	server.StartServer(New, "0.1", 1250)
+}
+
+func unauthorized(kong *pdk.PDK, message string) {
+	kong.Response.Exit(401, []byte(fmt.Sprintf(`{"message":%q}`, message)), map[string][]string{
+		"content-type":     {"application/json"},
+		"www-authenticate": {`Key realm="go-auth"`},
+	})
+}
+
+// findKey looks for an API key in the headers, then the query arguments.
+func (conf Config) findKey(kong *pdk.PDK) string {
+	for _, name := range conf.KeyNames {
+		if key, err := kong.Request.GetHeader(name); err == nil && key != "" {
+			return key
+		}
+		if key, err := kong.Request.GetQueryArg(name); err == nil && key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// username finds the consumer owning key, in the config or the keys file.
+func (conf Config) username(key string) (string, error) {
+	if username, ok := conf.Keys[key]; ok {
+		return username, nil
+	}
+	if conf.KeysFile == "" {
+		return "", nil
+	}
+
+	b, err := ioutil.ReadFile(conf.KeysFile)
+	if err != nil {
+		return "", err
+	}
+	keys := map[string]string{}
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return "", fmt.Errorf("%s: %w", conf.KeysFile, err)
+	}
+
+	return keys[key], nil
+}
+
+// verifyHmac returns the username of a correctly signed request.
+func (conf Config) verifyHmac(kong *pdk.PDK, authorization string) (string, bool) {
+	username, signature, found := strings.Cut(strings.TrimPrefix(authorization, "hmac "), ":")
+	secret, ok := conf.HmacSecrets[username]
+	if !found || !ok {
+		return "", false
+	}
+
+	method, _ := kong.Request.GetMethod()
+	path, _ := kong.Request.GetPath()
+	date, _ := kong.Request.GetHeader("x-date")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + " " + path + "\n" + date))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return username, hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	var username, credentialId string
+
+	authorization, _ := kong.Request.GetHeader("authorization")
+	if strings.HasPrefix(authorization, "hmac ") {
+		var ok bool
+		if username, ok = conf.verifyHmac(kong, authorization); !ok {
+			unauthorized(kong, "Invalid signature")
+			return
+		}
+		credentialId = "hmac:" + username
+
+	} else {
+		key := conf.findKey(kong)
+		if key == "" {
+			unauthorized(kong, "No API key found in request")
+			return
+		}
+
+		var err error
+		if username, err = conf.username(key); err != nil {
+			kong.Log.Err("go-auth: ", err.Error())
+			kong.Response.ExitStatus(500)
+			return
+		}
+		if username == "" {
+			unauthorized(kong, "Invalid authentication credentials")
+			return
+		}
+		credentialId = fmt.Sprintf("key:%x", sha256.Sum256([]byte(key)))[:20]
+	}
+
+	consumer, err := kong.Client.LoadConsumer(username, true)
+	if err != nil {
+		kong.Log.Err("go-auth: loading consumer ", username, ": ", err.Error())
+		unauthorized(kong, "Invalid authentication credentials")
+		return
+	}
+	// an unknown username loads no consumer, and no error either
+	if consumer.Id == "" {
+		unauthorized(kong, "Invalid authentication credentials")
+		return
+	}
+
+	credential := client.AuthenticatedCredential{Id: credentialId, ConsumerId: consumer.Id}
+	if err := kong.Client.Authenticate(&consumer, &credential); err != nil {
+		kong.Log.Err("go-auth: ", err.Error())
+		kong.Response.ExitStatus(500)
+		return
+	}
+
+	// read back what Kong now holds, as acl and rate-limiting will
+	var current entities.Consumer
+	if current, err = kong.Client.GetConsumer(); err != nil {
+		kong.Log.Err("go-auth: ", err.Error())
+		kong.Response.ExitStatus(500)
+		return
+	}
+
+	kong.ServiceRequest.SetHeader("x-consumer-id", current.Id)
+	kong.ServiceRequest.SetHeader("x-consumer-username", current.Username)
+	kong.ServiceRequest.SetHeader("x-credential-identifier", credentialId)
+	kong.ServiceRequest.ClearHeader("x-anonymous-consumer")
+}
@@ -0,0 +1,92 @@
+/*
+A misbehaving plugin in Go,
+for testing how Kong copes with a broken pluginserver.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	// panic, exit, hang, pdk_error, leak, or empty to behave
+	Mode string `json:"mode"`
+	// access or log, pdk_error is access only
+	Phase string `json:"phase"`
+	// how long hang blocks
+	HangMs int `json:"hang_ms"`
+	// goroutines leaked per request by leak
+	Leak int `json:"leak"`
+}
+
+func New() interface{} {
+	return &Config{Phase: "access", HangMs: 60000, Leak: 10}
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+// leaked never returns, nor is it ever closed
+var leaked = make(chan struct{})
+
+func (conf Config) misbehave(kong *pdk.PDK, phase string) {
+	if conf.Phase != phase {
+		return
+	}
+
+	switch conf.Mode {
+	case "panic":
+		// not recovered by the pluginserver, so the whole process dies
+		panic("go-chaos: panic in " + phase)
+
+	case "exit":
+		fmt.Fprintln(os.Stderr, "go-chaos: exiting in", phase)
+		os.Exit(3)
+
+	case "hang":
+		fmt.Fprintln(os.Stderr, "go-chaos: hanging in", phase, "for", conf.HangMs, "ms")
+		time.Sleep(time.Duration(conf.HangMs) * time.Millisecond)
+
+	case "pdk_error":
+		// returns an error, there is no such Upstream; calls Kong rejects
+		// by raising, like Response.SetStatus(1000), fail the whole
+		// request instead.  set_upstream is only allowed in access.
+		if phase != "access" {
+			kong.Log.Err("go-chaos: pdk_error only works in access")
+			return
+		}
+		err := kong.Service.SetUpstream("no-such-upstream")
+		if err == nil {
+			kong.Log.Err("go-chaos: expected a PDK error")
+			return
+		}
+		kong.Log.Err("go-chaos: PDK error in ", phase, ": ", err.Error())
+		kong.Response.SetHeader("x-go-chaos-error", err.Error())
+
+	case "leak":
+		for i := 0; i < conf.Leak; i++ {
+			go func() { <-leaked }()
+		}
+		if phase == "access" {
+			kong.Response.SetHeader("x-go-chaos-goroutines", strconv.Itoa(runtime.NumGoroutine()))
+		}
+	}
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	conf.misbehave(kong, "access")
+
+	kong.Response.SetHeader("x-go-chaos", "survived")
+}
+
+func (conf Config) Log(kong *pdk.PDK) {
+	conf.misbehave(kong, "log")
+}
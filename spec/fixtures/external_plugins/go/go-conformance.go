@@ -0,0 +1,320 @@
+/*
+A PDK conformance plugin in Go,
+which calls every function of the Go PDK and reports what came back.
+
+The access phase report is stored in the shared context, and completed
+in the response phase with the ServiceResponse and Response getter calls,
+which need a response, then sent to the client as JSON in the
+x-pdk-conformance header.  Log phase calls are reported in the error log.
+Each call is reported with the phase it was made in.  With exit_status set, the access phase ends
+the request instead, with Response.Exit and the report as body.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/client"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	ConsumerId string `json:"consumer_id"`
+	Upstream   string `json:"upstream"`
+	ExitStatus int    `json:"exit_status"`
+}
+
+func New() interface{} {
+	return &Config{}
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+// result is what one PDK call returned; Type tells a wrong type apart
+// from a wrong value, and Phase is the phase it was called in.
+type result struct {
+	Value interface{} `json:"value"`
+	Type  string      `json:"type"`
+	Error string      `json:"error,omitempty"`
+	Phase string      `json:"phase"`
+}
+
+type report map[string]result
+
+func (r report) record(name string, value interface{}, err error) {
+	res := result{Value: value, Type: fmt.Sprintf("%T", value)}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	r[name] = res
+}
+
+// stamp sets the phase of the calls recorded since the last stamp.
+func (r report) stamp(phase string) {
+	for name, res := range r {
+		if res.Phase == "" {
+			res.Phase = phase
+			r[name] = res
+		}
+	}
+}
+
+const reportKey = "pdk_conformance"
+
+// uriCaptures makes the captures readable in the report, as bytes are
+// base64 encoded in JSON.
+func uriCaptures(unnamed [][]byte, named map[string][]byte) map[string]interface{} {
+	u := []string{}
+	for _, v := range unnamed {
+		u = append(u, string(v))
+	}
+	n := map[string]string{}
+	for k, v := range named {
+		n[k] = string(v)
+	}
+
+	return map[string]interface{}{"unnamed": u, "named": n}
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	r := report{}
+
+	// Client
+	ip, err := kong.Client.GetIp()
+	r.record("client.get_ip", ip, err)
+	fip, err := kong.Client.GetForwardedIp()
+	r.record("client.get_forwarded_ip", fip, err)
+	port, err := kong.Client.GetPort()
+	r.record("client.get_port", port, err)
+	fport, err := kong.Client.GetForwardedPort()
+	r.record("client.get_forwarded_port", fport, err)
+	protocol, err := kong.Client.GetProtocol(false)
+	r.record("client.get_protocol", protocol, err)
+
+	consumer, err := kong.Client.LoadConsumer(conf.ConsumerId, false)
+	r.record("client.load_consumer", consumer, err)
+	if err == nil {
+		credential := client.AuthenticatedCredential{Id: "conformance", ConsumerId: consumer.Id}
+		err = kong.Client.Authenticate(&consumer, &credential)
+		r.record("client.authenticate", nil, err)
+	}
+	cred, err := kong.Client.GetCredential()
+	r.record("client.get_credential", cred, err)
+	current, err := kong.Client.GetConsumer()
+	r.record("client.get_consumer", current, err)
+
+	// Ctx
+	r.record("ctx.set_shared", nil, kong.Ctx.SetShared("conformance_string", "shared"))
+	kong.Ctx.SetShared("conformance_int", 42)
+	kong.Ctx.SetShared("conformance_float", 4.5)
+	sharedAny, err := kong.Ctx.GetSharedAny("conformance_string")
+	r.record("ctx.get_shared_any", sharedAny, err)
+	sharedString, err := kong.Ctx.GetSharedString("conformance_string")
+	r.record("ctx.get_shared_string", sharedString, err)
+	sharedInt, err := kong.Ctx.GetSharedInt("conformance_int")
+	r.record("ctx.get_shared_int", sharedInt, err)
+	sharedFloat, err := kong.Ctx.GetSharedFloat("conformance_float")
+	r.record("ctx.get_shared_float", sharedFloat, err)
+
+	// IP
+	trusted, err := kong.IP.IsTrusted("127.0.0.1")
+	r.record("ip.is_trusted", trusted, err)
+
+	// Log, phase-specific Serialize is in Log
+	r.record("log.alert", nil, kong.Log.Alert("pdk conformance alert"))
+	r.record("log.crit", nil, kong.Log.Crit("pdk conformance crit"))
+	r.record("log.err", nil, kong.Log.Err("pdk conformance err"))
+	r.record("log.warn", nil, kong.Log.Warn("pdk conformance warn"))
+	r.record("log.notice", nil, kong.Log.Notice("pdk conformance notice"))
+	r.record("log.info", nil, kong.Log.Info("pdk conformance info"))
+	r.record("log.debug", nil, kong.Log.Debug("pdk conformance debug"))
+	r.record("log.set_serialize_value", nil, kong.Log.SetSerializeValue("conformance", "serialized"))
+
+	// Nginx
+	uri, err := kong.Nginx.GetVar("request_uri")
+	r.record("nginx.get_var", uri, err)
+	tlsVersion, err := kong.Nginx.GetTLS1VersionStr()
+	r.record("nginx.get_tls1_version_str", tlsVersion, err)
+	r.record("nginx.set_ctx", nil, kong.Nginx.SetCtx("conformance_string", "ctx"))
+	kong.Nginx.SetCtx("conformance_int", 7)
+	kong.Nginx.SetCtx("conformance_float", 0.25)
+	ctxAny, err := kong.Nginx.GetCtxAny("conformance_string")
+	r.record("nginx.get_ctx_any", ctxAny, err)
+	ctxString, err := kong.Nginx.GetCtxString("conformance_string")
+	r.record("nginx.get_ctx_string", ctxString, err)
+	ctxInt, err := kong.Nginx.GetCtxInt("conformance_int")
+	r.record("nginx.get_ctx_int", ctxInt, err)
+	ctxFloat, err := kong.Nginx.GetCtxFloat("conformance_float")
+	r.record("nginx.get_ctx_float", ctxFloat, err)
+	startTime, err := kong.Nginx.ReqStartTime()
+	r.record("nginx.req_start_time", startTime, err)
+	subsystem, err := kong.Nginx.GetSubsystem()
+	r.record("nginx.get_subsystem", subsystem, err)
+
+	// Node
+	nodeId, err := kong.Node.GetId()
+	r.record("node.get_id", nodeId, err)
+	memory, err := kong.Node.GetMemoryStats()
+	r.record("node.get_memory_stats", memory, err)
+
+	// Request
+	scheme, err := kong.Request.GetScheme()
+	r.record("request.get_scheme", scheme, err)
+	host, err := kong.Request.GetHost()
+	r.record("request.get_host", host, err)
+	reqPort, err := kong.Request.GetPort()
+	r.record("request.get_port", reqPort, err)
+	fscheme, err := kong.Request.GetForwardedScheme()
+	r.record("request.get_forwarded_scheme", fscheme, err)
+	fhost, err := kong.Request.GetForwardedHost()
+	r.record("request.get_forwarded_host", fhost, err)
+	freqPort, err := kong.Request.GetForwardedPort()
+	r.record("request.get_forwarded_port", freqPort, err)
+	httpVersion, err := kong.Request.GetHttpVersion()
+	r.record("request.get_http_version", httpVersion, err)
+	method, err := kong.Request.GetMethod()
+	r.record("request.get_method", method, err)
+	path, err := kong.Request.GetPath()
+	r.record("request.get_path", path, err)
+	pathWithQuery, err := kong.Request.GetPathWithQuery()
+	r.record("request.get_path_with_query", pathWithQuery, err)
+	rawQuery, err := kong.Request.GetRawQuery()
+	r.record("request.get_raw_query", rawQuery, err)
+	queryArg, err := kong.Request.GetQueryArg("q")
+	r.record("request.get_query_arg", queryArg, err)
+	query, err := kong.Request.GetQuery(100)
+	r.record("request.get_query", query, err)
+	header, err := kong.Request.GetHeader("x-conformance-in")
+	r.record("request.get_header", header, err)
+	headers, err := kong.Request.GetHeaders(100)
+	r.record("request.get_headers", headers, err)
+	rawBody, err := kong.Request.GetRawBody()
+	r.record("request.get_raw_body", string(rawBody), err)
+	unnamed, named, err := kong.Request.GetUriCaptures()
+	r.record("request.get_uri_captures", uriCaptures(unnamed, named), err)
+
+	// Response setters; the getters only work once there is a response
+	r.record("response.set_status", nil, kong.Response.SetStatus(200))
+	r.record("response.set_header", nil, kong.Response.SetHeader("x-conformance-set", "1"))
+	r.record("response.add_header", nil, kong.Response.AddHeader("x-conformance-add", "1"))
+	kong.Response.AddHeader("x-conformance-add", "2")
+	kong.Response.SetHeader("x-conformance-clear", "1")
+	r.record("response.clear_header", nil, kong.Response.ClearHeader("x-conformance-clear"))
+	r.record("response.set_headers", nil, kong.Response.SetHeaders(map[string][]string{
+		"x-conformance-set-headers": {"a", "b"},
+	}))
+
+	// Router
+	route, err := kong.Router.GetRoute()
+	r.record("router.get_route", route, err)
+	service, err := kong.Router.GetService()
+	r.record("router.get_service", service, err)
+
+	// Service; set_upstream fails without an Upstream of that name, and
+	// set_target then points at the service itself
+	r.record("service.set_upstream", nil, kong.Service.SetUpstream(conf.Upstream))
+	r.record("service.set_target", nil, kong.Service.SetTarget(service.Host, service.Port))
+
+	// ServiceRequest, echoed back by the mock upstream's /anything
+	r.record("service.request.set_scheme", nil, kong.ServiceRequest.SetScheme("http"))
+	r.record("service.request.set_method", nil, kong.ServiceRequest.SetMethod("POST"))
+	r.record("service.request.set_path", nil, kong.ServiceRequest.SetPath("/anything"))
+	r.record("service.request.set_raw_query", nil, kong.ServiceRequest.SetRawQuery("raw=1"))
+	r.record("service.request.set_query", nil, kong.ServiceRequest.SetQuery(map[string][]string{
+		"conformance": {"1", "2"},
+	}))
+	r.record("service.request.set_header", nil, kong.ServiceRequest.SetHeader("x-conformance", "set"))
+	r.record("service.request.add_header", nil, kong.ServiceRequest.AddHeader("x-conformance-added", "added"))
+	r.record("service.request.clear_header", nil, kong.ServiceRequest.ClearHeader("x-conformance-in"))
+	r.record("service.request.set_headers", nil, kong.ServiceRequest.SetHeaders(map[string][]string{
+		"x-conformance-headers": {"a", "b"},
+	}))
+	r.record("service.request.set_raw_body", nil, kong.ServiceRequest.SetRawBody("conformance body"))
+	r.stamp("access")
+
+	if conf.ExitStatus != 0 {
+		body, err := json.Marshal(r)
+		if err != nil {
+			kong.Log.Err(err.Error())
+			kong.Response.ExitStatus(500)
+			return
+		}
+		kong.Response.Exit(conf.ExitStatus, body, map[string][]string{
+			"content-type": {"application/json"},
+		})
+		return
+	}
+
+	saved, err := json.Marshal(r)
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+	kong.Ctx.SetShared(reportKey, string(saved))
+}
+
+func (conf Config) Response(kong *pdk.PDK) {
+	r := report{}
+	if saved, err := kong.Ctx.GetSharedString(reportKey); err == nil {
+		if err := json.Unmarshal([]byte(saved), &r); err != nil {
+			kong.Log.Err(err.Error())
+		}
+	}
+
+	// ServiceResponse
+	status, err := kong.ServiceResponse.GetStatus()
+	r.record("service.response.get_status", status, err)
+	header, err := kong.ServiceResponse.GetHeader("content-type")
+	r.record("service.response.get_header", header, err)
+	headers, err := kong.ServiceResponse.GetHeaders(100)
+	r.record("service.response.get_headers", headers, err)
+	rawBody, err := kong.ServiceResponse.GetRawBody()
+	r.record("service.response.get_raw_body", string(rawBody), err)
+
+	// Response, with the upstream's answer
+	respStatus, err := kong.Response.GetStatus()
+	r.record("response.get_status", respStatus, err)
+	respHeader, err := kong.Response.GetHeader("x-conformance-none")
+	r.record("response.get_header", respHeader, err)
+	respHeaders, err := kong.Response.GetHeaders(100)
+	r.record("response.get_headers", respHeaders, err)
+	source, err := kong.Response.GetSource()
+	r.record("response.get_source", source, err)
+	r.stamp("response")
+
+	out, err := json.Marshal(r)
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+	kong.Response.SetHeader("x-pdk-conformance", string(out))
+}
+
+func (conf Config) Log(kong *pdk.PDK) {
+	r := report{}
+
+	serialized, err := kong.Log.Serialize()
+	r.record("log.serialize", serialized, err)
+
+	// request data saved by Kong for the log phase
+	status, err := kong.Response.GetStatus()
+	r.record("response.get_status", status, err)
+	header, err := kong.Request.GetHeader("x-conformance-in")
+	r.record("request.get_header", header, err)
+	shared, err := kong.Ctx.GetSharedString("conformance_string")
+	r.record("ctx.get_shared_string", shared, err)
+	ctxString, err := kong.Nginx.GetCtxString("conformance_string")
+	r.record("nginx.get_ctx_string", ctxString, err)
+	r.stamp("log")
+
+	out, err := json.Marshal(r)
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+	kong.Log.Notice("pdk conformance log phase: ", string(out))
+}
@@ -0,0 +1,109 @@
+/*
+A plugin in Go acting on gRPC calls.
+
+Access reports what the PDK shows of the call in the x-go-grpc-seen
+response metadata, then sets and clears request metadata on the way to
+the upstream, listing the keys set in x-go-grpc-set.  Calls carrying the
+reject.when metadata are answered by the plugin instead: with
+reject.grpc_status as the grpc-status if set, or else with
+reject.http_status for Kong to map to a grpc-status.
+*/
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Reject struct {
+	When       map[string]string `json:"when"`
+	GrpcStatus int               `json:"grpc_status"`
+	HttpStatus int               `json:"http_status"`
+	Message    string            `json:"message"`
+}
+
+type Config struct {
+	// request metadata to report in x-go-grpc-seen
+	Read  []string          `json:"read"`
+	Set   map[string]string `json:"set"`
+	Clear []string          `json:"clear"`
+	// grpc_status 0 leaves the grpc-status to Kong
+	Reject Reject `json:"reject"`
+}
+
+func New() interface{} {
+	return &Config{
+		Reject: Reject{HttpStatus: 403, Message: "go-grpc: rejected"},
+	}
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+func (conf Config) rejects(kong *pdk.PDK) bool {
+	if len(conf.Reject.When) == 0 {
+		return false
+	}
+	for k, v := range conf.Reject.When {
+		if got, _ := kong.Request.GetHeader(k); got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	method, _ := kong.Request.GetMethod()
+	path, _ := kong.Request.GetPath()
+	version, _ := kong.Request.GetHttpVersion()
+	contentType, _ := kong.Request.GetHeader("content-type")
+
+	seen := []string{
+		"method=" + method,
+		"path=" + path,
+		"http=" + strconv.FormatFloat(version, 'f', -1, 64),
+		"content-type=" + contentType,
+	}
+	for _, k := range conf.Read {
+		v, _ := kong.Request.GetHeader(k)
+		seen = append(seen, k+"="+v)
+	}
+	kong.Response.SetHeader("x-go-grpc-seen", strings.Join(seen, " "))
+
+	if conf.rejects(kong) {
+		status := conf.Reject.HttpStatus
+		headers := map[string][]string{}
+		if conf.Reject.GrpcStatus != 0 {
+			// like gRPC servers, which always answer 200
+			status = 200
+			headers["grpc-status"] = []string{strconv.Itoa(conf.Reject.GrpcStatus)}
+		}
+		kong.Response.Exit(status, []byte(conf.Reject.Message), headers)
+		return
+	}
+
+	// sorted, for a stable x-go-grpc-set
+	keys := make([]string, 0, len(conf.Set))
+	for k := range conf.Set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := kong.ServiceRequest.SetHeader(k, conf.Set[k]); err != nil {
+			kong.Log.Err(fmt.Sprintf("go-grpc: setting %s: %v", k, err))
+		}
+	}
+	for _, k := range conf.Clear {
+		if err := kong.ServiceRequest.ClearHeader(k); err != nil {
+			kong.Log.Err(fmt.Sprintf("go-grpc: clearing %s: %v", k, err))
+		}
+	}
+	kong.Response.SetHeader("x-go-grpc-set", strings.Join(keys, ","))
+}
@@ -5,23 +5,106 @@ which reads a request header and sets a response header.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
+
 	"github.com/Kong/go-pdk"
 	"github.com/Kong/go-pdk/server"
 )
 
+// Config covers every kind of field -dump turns into a schema type.  The
+// json tags name the fields, and the default tags are applied by New, as
+// fields Kong leaves unset arrive as null and keep their Go value.
 type Config struct {
-	Message string
+	Message string            `json:"message"`
+	Count   int               `json:"count" default:"1"`
+	Ratio   float64           `json:"ratio" default:"0.5"`
+	Enabled bool              `json:"enabled" default:"true"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Limits  Limits            `json:"limits"`
+	Timeout *int              `json:"timeout"`
+	Note    *string           `json:"note"`
+}
+
+type Limits struct {
+	Second int    `json:"second" default:"10"`
+	Minute int    `json:"minute"`
+	Policy string `json:"policy" default:"local"`
+}
+
+// setDefaults fills the fields of a struct from their default tags.
+func setDefaults(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			setDefaults(field)
+			continue
+		}
+
+		def, ok := t.Field(i).Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		if field.Kind() == reflect.String {
+			field.SetString(def)
+			continue
+		}
+		if err := json.Unmarshal([]byte(def), field.Addr().Interface()); err != nil {
+			panic(fmt.Sprintf("bad default for %s: %v", t.Field(i).Name, err))
+		}
+	}
 }
 
 func New() interface{} {
-	return &Config{}
+	conf := &Config{}
+	setDefaults(reflect.ValueOf(conf).Elem())
+	return conf
 }
 
 func main() {
 	server.StartServer(New, "0.1", 1)
 }
 
+// Certificate only runs for global plugins, during the TLS handshake,
+// where ngx.var and the request don't exist yet.
+func (conf Config) Certificate(kong *pdk.PDK) {
+	version, err := kong.Nginx.GetTLS1VersionStr()
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+	kong.Log.Notice("go-hello certificate phase, handshake version ", version)
+}
+
+// Rewrite only runs for global plugins, before the route is known.
+func (conf Config) Rewrite(kong *pdk.PDK) {
+	method, err := kong.Request.GetMethod()
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+	path, err := kong.Request.GetPath()
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+
+	// empty over plain HTTP
+	var tls []string
+	for _, name := range []string{"ssl_server_name", "ssl_client_verify", "ssl_client_s_dn"} {
+		value, err := kong.Nginx.GetVar(name)
+		if err != nil {
+			kong.Log.Err(err.Error())
+		}
+		tls = append(tls, value)
+	}
+
+	seen := fmt.Sprintf("%s %s sni=%s client_verify=%s client_dn=%s", method, path, tls[0], tls[1], tls[2])
+	kong.Response.SetHeader("x-hello-from-go-at-rewrite", seen)
+	kong.Ctx.SetShared("rewrite_seen", seen)
+}
+
 func (conf Config) Access(kong *pdk.PDK) {
 	host, err := kong.Request.GetHeader("host")
 	if err != nil {
@@ -33,6 +116,13 @@ func (conf Config) Access(kong *pdk.PDK) {
 	}
 	kong.Response.SetHeader("x-hello-from-go", fmt.Sprintf("Go says %s to %s", message, host))
 	kong.Ctx.SetShared("shared_msg", message)
+
+	// the config as decoded on this side of the RPC boundary
+	decoded, err := json.Marshal(conf)
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+	kong.Response.SetHeader("x-hello-from-go-config", string(decoded))
 }
 
 func (conf Config) Log(kong *pdk.PDK) {
@@ -61,6 +151,13 @@ func (conf Config) Log(kong *pdk.PDK) {
 
 	kong.Log.Debug("shared_msg: ", shared_msg)
 
+	rewrite_seen, err := kong.Ctx.GetSharedString("rewrite_seen")
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+
+	kong.Log.Debug("rewrite_seen: ", rewrite_seen)
+
 	serialized, err := kong.Log.Serialize()
 	if err != nil {
 		kong.Log.Err(err.Error())
@@ -0,0 +1,96 @@
+/*
+A plugin in Go keeping per instance state, for watching Kong start and
+drop instances as plugin configurations change.
+
+Each instance gets an ID and a start time, and counts the requests it
+handles.  Access reports them in the x-instance-* headers, along with
+the counts of every instance of this pluginserver in x-instances, so
+instances that stopped receiving calls show as frozen counts.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	Tag string `json:"tag"`
+}
+
+// instance is the state of the instance holding a Config, kept apart so
+// it doesn't show in the schema.
+type instance struct {
+	id      string
+	started time.Time
+	count   int64
+}
+
+// fixed width, so start times compare as strings
+const startedFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+var (
+	lastId uint64
+
+	instancesMu sync.Mutex
+	instances   = map[*Config]*instance{}
+)
+
+// New is called once for each instance Kong starts
+func New() interface{} {
+	conf := &Config{}
+
+	instancesMu.Lock()
+	instances[conf] = &instance{
+		id:      fmt.Sprintf("%d-%d", os.Getpid(), atomic.AddUint64(&lastId, 1)),
+		started: time.Now(),
+	}
+	instancesMu.Unlock()
+
+	return conf
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+// counts returns the requests handled by every instance called so far.
+func counts() map[string]int64 {
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+
+	c := make(map[string]int64, len(instances))
+	for _, in := range instances {
+		if n := atomic.LoadInt64(&in.count); n > 0 {
+			c[in.id] = n
+		}
+	}
+	return c
+}
+
+func (conf *Config) Access(kong *pdk.PDK) {
+	instancesMu.Lock()
+	in := instances[conf]
+	instancesMu.Unlock()
+
+	count := atomic.AddInt64(&in.count, 1)
+
+	kong.Response.SetHeader("x-instance-id", in.id)
+	kong.Response.SetHeader("x-instance-started", in.started.UTC().Format(startedFormat))
+	kong.Response.SetHeader("x-instance-count", strconv.FormatInt(count, 10))
+	kong.Response.SetHeader("x-instance-tag", conf.Tag)
+
+	all, err := json.Marshal(counts())
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+	kong.Response.SetHeader("x-instances", string(all))
+}
@@ -0,0 +1,262 @@
+/*
+A log shipping plugin in Go,
+which batches serialized log entries and sends them to a sink.
+
+Entries are queued by the Log phase and flushed by a background goroutine
+when batch_size entries are waiting or every flush_ms.  Once queue_size
+entries are waiting, new ones are dropped instead of slowing requests
+down.  Every batch is sent as
+
+	{"entries": [...], "dropped": N}
+
+with N the entries dropped so far, as an HTTP POST body, a line on a TCP
+connection, or a line appended to a file; see Config.Sink.  Queued
+entries are flushed when the pluginserver is stopped.  Configurations
+with a non-positive size or period ship nothing and log why instead.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	// http://HOST:PORT/PATH, tcp://HOST:PORT or file:///PATH
+	Sink      string `json:"sink"`
+	BatchSize int    `json:"batch_size"`
+	FlushMs   int    `json:"flush_ms"`
+	QueueSize int    `json:"queue_size"`
+}
+
+func New() interface{} {
+	return &Config{BatchSize: 100, FlushMs: 1000, QueueSize: 10000}
+}
+
+// check rejects sizes and periods the shipper can't work with: a zero
+// batch_size would never empty the queue, a zero flush_ms can't tick.
+func (conf Config) check() error {
+	for _, v := range []struct {
+		name  string
+		value int
+	}{
+		{"batch_size", conf.BatchSize},
+		{"flush_ms", conf.FlushMs},
+		{"queue_size", conf.QueueSize},
+	} {
+		if v.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", v.name, v.value)
+		}
+	}
+	return nil
+}
+
+func main() {
+	go flushOnSignal()
+	server.StartServer(New, "0.1", 1)
+}
+
+type batch struct {
+	Entries []json.RawMessage `json:"entries"`
+	Dropped uint64            `json:"dropped"`
+}
+
+// shipper owns the pending entries and the flushing goroutine of one
+// configuration; plugin instances with the same config share it.
+type shipper struct {
+	conf    Config
+	mu      sync.Mutex
+	pending []json.RawMessage
+	dropped uint64
+	// wakes up run when a batch is full
+	full chan struct{}
+	stop chan chan struct{}
+}
+
+var (
+	shippersMu sync.Mutex
+	shippers   = map[Config]*shipper{}
+)
+
+func getShipper(conf Config) *shipper {
+	shippersMu.Lock()
+	defer shippersMu.Unlock()
+
+	s, ok := shippers[conf]
+	if !ok {
+		s = &shipper{
+			conf: conf,
+			full: make(chan struct{}, 1),
+			stop: make(chan chan struct{}),
+		}
+		shippers[conf] = s
+		go s.run()
+	}
+
+	return s
+}
+
+// flushOnSignal flushes every shipper when Kong stops the pluginserver.
+func flushOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	<-signals
+
+	shippersMu.Lock()
+	for _, s := range shippers {
+		done := make(chan struct{})
+		s.stop <- done
+		<-done
+	}
+	shippersMu.Unlock()
+
+	os.Exit(0)
+}
+
+// enqueue adds an entry to the next batch, unless queue_size entries are
+// already waiting to be sent.
+func (s *shipper) enqueue(entry json.RawMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) >= s.conf.QueueSize {
+		s.dropped++
+		return false
+	}
+
+	s.pending = append(s.pending, entry)
+	if len(s.pending) >= s.conf.BatchSize {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return true
+}
+
+func (s *shipper) droppedSoFar() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dropped
+}
+
+// flush sends the pending entries, batch_size at a time.
+func (s *shipper) flush() {
+	s.mu.Lock()
+	entries := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > s.conf.BatchSize {
+			n = s.conf.BatchSize
+		}
+
+		if err := s.send(entries[:n]); err != nil {
+			s.mu.Lock()
+			s.dropped += uint64(n)
+			s.mu.Unlock()
+			fmt.Fprintf(os.Stderr, "go-logship: dropping %d entries: %v\n", n, err)
+		}
+		entries = entries[n:]
+	}
+}
+
+func (s *shipper) run() {
+	ticker := time.NewTicker(time.Duration(s.conf.FlushMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.full:
+			s.flush()
+
+		case <-ticker.C:
+			s.flush()
+
+		case done := <-s.stop:
+			s.flush()
+			close(done)
+			return
+		}
+	}
+}
+
+func (s *shipper) send(entries []json.RawMessage) error {
+	payload, err := json.Marshal(batch{Entries: entries, Dropped: s.droppedSoFar()})
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(s.conf.Sink)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		client := http.Client{Timeout: 10 * time.Second}
+		res, err := client.Post(s.conf.Sink, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			return fmt.Errorf("sink answered %s", res.Status)
+		}
+		return nil
+
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = conn.Write(append(payload, '\n'))
+		return err
+
+	case "file":
+		f, err := os.OpenFile(u.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write(append(payload, '\n'))
+		return err
+	}
+
+	return fmt.Errorf("unsupported sink %q", s.conf.Sink)
+}
+
+func (conf Config) Log(kong *pdk.PDK) {
+	if err := conf.check(); err != nil {
+		kong.Log.Err("go-logship: ", err.Error())
+		return
+	}
+
+	serialized, err := kong.Log.Serialize()
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+
+	s := getShipper(conf)
+	if !s.enqueue(json.RawMessage(serialized)) {
+		kong.Log.Warn("go-logship: queue full, ", s.droppedSoFar(), " entries dropped so far")
+	}
+}
@@ -0,0 +1,326 @@
+/*
+A pluginserver hosting two go-pdk plugins, go-multi-a and go-multi-b, for
+checking Kong routes the calls of each plugin of a shared process to the
+right one.
+
+server.StartServer of go-pdk serves the one plugin the binary is named
+after, so this one answers the pluginserver calls of
+kong/include/kong/pluginsocket.proto itself, with the messages go-pdk
+generates from it, and runs the plugins the way go-pdk does:
+
+  - -dump lists both plugins;
+  - cmd_start_instance decodes the config into what the New of the plugin
+    it names returns, and cmd_close_instance drops that instance;
+  - cmd_handle_event calls the Access of the instance with a go-pdk PDK
+    over the connection.
+
+Both plugins report the configured tag, an instance ID prefixed by the
+server PID and the requests the instance handled, in the x-<plugin name>-*
+response headers.  Calls for instances it doesn't know close the
+connection, which makes Kong start them again.
+*/
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server/kong_plugin_protocol"
+	"google.golang.org/protobuf/proto"
+)
+
+// ConfigA and ConfigB are plugins as they would be written for
+// server.StartServer, each on its own.
+type ConfigA struct {
+	Tag string `json:"tag"`
+
+	counter
+}
+
+func NewA() interface{} {
+	return &ConfigA{counter: newCounter()}
+}
+
+func (conf *ConfigA) Access(kong *pdk.PDK) {
+	conf.report(kong, "go-multi-a", conf.Tag)
+}
+
+type ConfigB struct {
+	Tag string `json:"tag"`
+
+	counter
+}
+
+func NewB() interface{} {
+	return &ConfigB{counter: newCounter()}
+}
+
+func (conf *ConfigB) Access(kong *pdk.PDK) {
+	conf.report(kong, "go-multi-b", conf.Tag)
+}
+
+// counter numbers the instances of both plugins, and counts the requests
+// each handles.
+type counter struct {
+	serial   int32
+	requests *int64
+}
+
+var lastSerial int32
+
+func newCounter() counter {
+	return counter{serial: atomic.AddInt32(&lastSerial, 1), requests: new(int64)}
+}
+
+func (c counter) report(kong *pdk.PDK, name, tag string) {
+	count := atomic.AddInt64(c.requests, 1)
+
+	kong.Response.SetHeader("x-"+name+"-tag", tag)
+	kong.Response.SetHeader("x-"+name+"-instance", fmt.Sprintf("%d-%d", os.Getpid(), c.serial))
+	kong.Response.SetHeader("x-"+name+"-count", strconv.FormatInt(count, 10))
+}
+
+// plugin is what server.StartServer takes, for each plugin served.
+type plugin struct {
+	name        string
+	constructor func() interface{}
+	version     string
+	priority    int
+}
+
+var plugins = []plugin{
+	// a before b
+	{"go-multi-a", NewA, "0.1", 20},
+	{"go-multi-b", NewB, "0.1", 10},
+}
+
+type instance struct {
+	id     int32
+	plugin plugin
+	config interface{}
+}
+
+var (
+	instancesMu sync.Mutex
+	lastId      int32
+	instances   = map[int32]*instance{}
+)
+
+var (
+	dump       = flag.Bool("dump", false, "dump info about the plugins")
+	kongPrefix = flag.String("kong-prefix", "/usr/local/kong", "Kong prefix path")
+)
+
+func main() {
+	flag.Parse()
+
+	if *dump {
+		dumpInfo()
+		return
+	}
+
+	socket := filepath.Join(*kongPrefix, "go-multi.socket")
+	os.Remove(socket)
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Fatalf("go-multi: %v", err)
+	}
+	log.Printf("go-multi: serving %d plugins on %s", len(plugins), socket)
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			log.Fatalf("go-multi: %v", err)
+		}
+		go serve(conn)
+	}
+}
+
+func dumpInfo() {
+	type info struct {
+		Name     string
+		Version  string
+		Priority int
+		Phases   []string
+		Schema   interface{}
+	}
+
+	var infos []info
+	for _, p := range plugins {
+		infos = append(infos, info{
+			Name:     p.name,
+			Version:  p.version,
+			Priority: p.priority,
+			Phases:   []string{"access"},
+			Schema: map[string]interface{}{
+				"name": p.name,
+				"fields": []interface{}{
+					map[string]interface{}{
+						"config": map[string]interface{}{
+							"type": "record",
+							"fields": []interface{}{
+								map[string]interface{}{
+									"tag": map[string]interface{}{"type": "string"},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+		"Protocol": "ProtoBuf:1",
+		"Plugins":  infos,
+	})
+}
+
+// serve answers the calls Kong makes on conn, which it keeps alive.
+func serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		msg, err := readFrame(conn)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("go-multi: %v", err)
+			}
+			return
+		}
+
+		call := &kong_plugin_protocol.RpcCall{}
+		if err := proto.Unmarshal(msg, call); err != nil {
+			log.Printf("go-multi: %v", err)
+			return
+		}
+
+		ret, err := handleCall(conn, call)
+		if err != nil {
+			log.Printf("go-multi: %v", err)
+			return
+		}
+
+		out, err := proto.Marshal(ret)
+		if err != nil {
+			log.Printf("go-multi: %v", err)
+			return
+		}
+		if err := writeFrame(conn, out); err != nil {
+			log.Printf("go-multi: %v", err)
+			return
+		}
+	}
+}
+
+func handleCall(conn net.Conn, call *kong_plugin_protocol.RpcCall) (*kong_plugin_protocol.RpcReturn, error) {
+	ret := &kong_plugin_protocol.RpcReturn{Sequence: call.Sequence}
+
+	switch c := call.Call.(type) {
+	case *kong_plugin_protocol.RpcCall_CmdStartInstance:
+		in, err := startInstance(c.CmdStartInstance.Name, c.CmdStartInstance.Config)
+		if err != nil {
+			return nil, err
+		}
+		ret.Return = &kong_plugin_protocol.RpcReturn_InstanceStatus{
+			InstanceStatus: &kong_plugin_protocol.InstanceStatus{
+				Name:       in.plugin.name,
+				InstanceId: in.id,
+				StartedAt:  time.Now().Unix(),
+			},
+		}
+
+	case *kong_plugin_protocol.RpcCall_CmdCloseInstance:
+		id := c.CmdCloseInstance.InstanceId
+		instancesMu.Lock()
+		delete(instances, id)
+		instancesMu.Unlock()
+		log.Printf("go-multi: closed instance %d", id)
+
+	case *kong_plugin_protocol.RpcCall_CmdHandleEvent:
+		if err := handleEvent(conn, c.CmdHandleEvent.InstanceId, c.CmdHandleEvent.EventName); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported call %T", call.Call)
+	}
+
+	return ret, nil
+}
+
+func startInstance(name string, config []byte) (*instance, error) {
+	var in *instance
+	for _, p := range plugins {
+		if p.name == name {
+			in = &instance{plugin: p, config: p.constructor()}
+		}
+	}
+	if in == nil {
+		return nil, fmt.Errorf("no plugin %q", name)
+	}
+
+	if err := json.Unmarshal(config, in.config); err != nil {
+		return nil, fmt.Errorf("%s config: %w", name, err)
+	}
+
+	instancesMu.Lock()
+	lastId++
+	in.id = lastId
+	instances[in.id] = in
+	instancesMu.Unlock()
+
+	log.Printf("go-multi: started instance %d of %s", in.id, name)
+	return in, nil
+}
+
+func handleEvent(conn net.Conn, id int32, event string) error {
+	instancesMu.Lock()
+	in := instances[id]
+	instancesMu.Unlock()
+
+	if in == nil {
+		return fmt.Errorf("no plugin instance %d", id)
+	}
+	h, ok := in.config.(interface{ Access(*pdk.PDK) })
+	if event != "access" || !ok {
+		return fmt.Errorf("%s: no %s handler", in.plugin.name, event)
+	}
+
+	// PDK calls are frames on conn until the empty one ending the event
+	h.Access(pdk.Init(conn))
+	return writeFrame(conn, nil)
+}
+
+// Frames are a native (little) endian uint32 length, then the message.
+
+func readFrame(conn net.Conn) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(conn, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeFrame(conn net.Conn, msg []byte) error {
+	frame := binary.LittleEndian.AppendUint32(nil, uint32(len(msg)))
+	_, err := conn.Write(append(frame, msg...))
+	return err
+}
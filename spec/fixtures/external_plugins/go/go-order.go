@@ -0,0 +1,71 @@
+/*
+A plugin in Go appending its name to the x-plugin-order response header,
+for checking where Kong runs external plugins among the others.
+
+The order is kept in the plugin_order shared context value during access,
+where the order-tracker Lua plugins append to it too, and written to the
+response in the Response phase, as response headers can't be read before.
+
+The priority and version are set at build time, and the name is the one
+of the binary, so differently ordered variants are built with
+
+	go build -o go-order-first \
+		-ldflags "-X main.priority=3000 -X main.version=3.0.0" go-order.go
+*/
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+var (
+	priority = "1"
+	version  = "0.1"
+)
+
+// the plugin name go-pdk reports, from the binary
+var name = filepath.Base(os.Args[0])
+
+type Config struct{}
+
+func New() interface{} {
+	return &Config{}
+}
+
+func main() {
+	p, err := strconv.Atoi(priority)
+	if err != nil {
+		panic("go-order: bad priority " + priority)
+	}
+
+	server.StartServer(New, version, p)
+}
+
+const orderKey = "plugin_order"
+
+func (conf Config) Access(kong *pdk.PDK) {
+	v, err := kong.Ctx.GetSharedAny(orderKey)
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+	// unset for the first plugin
+	order, _ := v.(string)
+	if order != "" {
+		order += ","
+	}
+	kong.Ctx.SetShared(orderKey, order+name)
+}
+
+func (conf Config) Response(kong *pdk.PDK) {
+	order, err := kong.Ctx.GetSharedString(orderKey)
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+	kong.Response.SetHeader("x-plugin-order", order)
+}
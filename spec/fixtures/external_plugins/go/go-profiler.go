@@ -0,0 +1,233 @@
+/*
+A plugin in Go timing PDK calls over the pluginserver socket,
+to keep an eye on the cost of the external plugin bridge.
+
+Each phase calls a set of representative PDK functions iterations times
+and builds a latency histogram of every function, in microseconds.  The
+phases are those go-pdk handles on HTTP requests: rewrite, which Kong
+only runs for global plugins, access, response and log.  go-pdk offers
+no header_filter; response, which buffers the whole response, takes its
+place.  The access phase summary goes in the x-pdk-profile response
+header, and every phase appends its full profile to dump_file, one JSON
+object per line:
+
+	{"phase": "access", "iterations": 100, "calls": {"kong.request.get_header": {...}}}
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	Iterations int    `json:"iterations"`
+	DumpFile   string `json:"dump_file"`
+}
+
+func New() interface{} {
+	return &Config{Iterations: 100}
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+// upper bounds of the histogram buckets, in microseconds
+var buckets = []float64{50, 100, 200, 500, 1000, 2000, 5000, 10000, math.Inf(1)}
+
+type histogram struct {
+	Count   int            `json:"count"`
+	Errors  int            `json:"errors"`
+	MinUs   float64        `json:"min_us"`
+	MeanUs  float64        `json:"mean_us"`
+	P50Us   float64        `json:"p50_us"`
+	P99Us   float64        `json:"p99_us"`
+	MaxUs   float64        `json:"max_us"`
+	Buckets map[string]int `json:"buckets,omitempty"`
+}
+
+func bucketName(le float64) string {
+	if math.IsInf(le, 1) {
+		return "le_inf"
+	}
+	return fmt.Sprintf("le_%g", le)
+}
+
+// percentile of sorted samples, by the nearest rank
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func newHistogram(samples []float64, errors int) histogram {
+	sort.Float64s(samples)
+
+	h := histogram{Count: len(samples), Errors: errors, Buckets: map[string]int{}}
+	for _, le := range buckets {
+		h.Buckets[bucketName(le)] = 0
+	}
+	if len(samples) == 0 {
+		return h
+	}
+
+	sum := 0.0
+	for _, s := range samples {
+		sum += s
+		for _, le := range buckets {
+			if s <= le {
+				h.Buckets[bucketName(le)]++
+				break
+			}
+		}
+	}
+
+	h.MinUs = samples[0]
+	h.MaxUs = samples[len(samples)-1]
+	h.MeanUs = sum / float64(len(samples))
+	h.P50Us = percentile(samples, 50)
+	h.P99Us = percentile(samples, 99)
+	return h
+}
+
+type profile struct {
+	Phase      string               `json:"phase"`
+	Iterations int                  `json:"iterations"`
+	Calls      map[string]histogram `json:"calls"`
+}
+
+type call struct {
+	name string
+	fn   func() error
+}
+
+// run times every call conf.Iterations times, interleaved so that they
+// share any slowdown of the pluginserver.
+func (conf Config) run(phase string, calls []call) profile {
+	samples := make([][]float64, len(calls))
+	errors := make([]int, len(calls))
+
+	for i := 0; i < conf.Iterations; i++ {
+		for j, c := range calls {
+			start := time.Now()
+			err := c.fn()
+			samples[j] = append(samples[j], float64(time.Since(start).Nanoseconds())/1000)
+			if err != nil {
+				errors[j]++
+			}
+		}
+	}
+
+	p := profile{Phase: phase, Iterations: conf.Iterations, Calls: map[string]histogram{}}
+	for j, c := range calls {
+		p.Calls[c.name] = newHistogram(samples[j], errors[j])
+	}
+	return p
+}
+
+func (conf Config) dump(kong *pdk.PDK, p profile) {
+	if conf.DumpFile == "" {
+		return
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+
+	f, err := os.OpenFile(conf.DumpFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		kong.Log.Err("go-profiler: ", err.Error())
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		kong.Log.Err("go-profiler: ", err.Error())
+	}
+}
+
+// ignore drops the result of a PDK getter, keeping its error
+func ignore[T any](_ T, err error) error {
+	return err
+}
+
+// calls common to every phase
+func common(kong *pdk.PDK) []call {
+	return []call{
+		{"kong.request.get_method", func() error { return ignore(kong.Request.GetMethod()) }},
+		{"kong.request.get_header", func() error { return ignore(kong.Request.GetHeader("host")) }},
+		{"kong.request.get_headers", func() error { return ignore(kong.Request.GetHeaders(-1)) }},
+		{"kong.client.get_ip", func() error { return ignore(kong.Client.GetIp()) }},
+		{"kong.nginx.get_var", func() error { return ignore(kong.Nginx.GetVar("request_id")) }},
+		{"kong.ctx.shared.set", func() error { return kong.Ctx.SetShared("go_profiler", "value") }},
+		{"kong.ctx.shared.get", func() error { return ignore(kong.Ctx.GetSharedString("go_profiler")) }},
+		{"kong.node.get_id", func() error { return ignore(kong.Node.GetId()) }},
+	}
+}
+
+func (conf Config) Rewrite(kong *pdk.PDK) {
+	calls := append(common(kong),
+		call{"kong.service.request.set_header", func() error {
+			return kong.ServiceRequest.SetHeader("x-pdk-profiled-rewrite", "true")
+		}},
+	)
+	conf.dump(kong, conf.run("rewrite", calls))
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	calls := append(common(kong),
+		call{"kong.response.set_header", func() error { return kong.Response.SetHeader("x-pdk-profiled", "true") }},
+		call{"kong.service.request.set_header", func() error {
+			return kong.ServiceRequest.SetHeader("x-pdk-profiled", "true")
+		}},
+	)
+	p := conf.run("access", calls)
+	conf.dump(kong, p)
+
+	// the header only carries the summary, buckets make it too large
+	summary := map[string]histogram{}
+	for name, h := range p.Calls {
+		h.Buckets = nil
+		summary[name] = h
+	}
+	b, err := json.Marshal(profile{Phase: p.Phase, Iterations: p.Iterations, Calls: summary})
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+	kong.Response.SetHeader("x-pdk-profile", string(b))
+}
+
+func (conf Config) Response(kong *pdk.PDK) {
+	calls := append(common(kong),
+		call{"kong.service.response.get_status", func() error { return ignore(kong.ServiceResponse.GetStatus()) }},
+		call{"kong.service.response.get_header", func() error {
+			return ignore(kong.ServiceResponse.GetHeader("content-type"))
+		}},
+		call{"kong.response.get_status", func() error { return ignore(kong.Response.GetStatus()) }},
+		call{"kong.response.set_header", func() error {
+			return kong.Response.SetHeader("x-pdk-profiled-response", "true")
+		}},
+	)
+	conf.dump(kong, conf.run("response", calls))
+}
+
+func (conf Config) Log(kong *pdk.PDK) {
+	calls := append(common(kong),
+		call{"kong.response.get_status", func() error { return ignore(kong.Response.GetStatus()) }},
+		call{"kong.log.serialize", func() error { return ignore(kong.Log.Serialize()) }},
+	)
+	conf.dump(kong, conf.run("log", calls))
+}
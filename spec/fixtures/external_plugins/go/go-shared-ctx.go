@@ -0,0 +1,91 @@
+/*
+A plugin in Go sharing values with Lua plugins through kong.ctx.shared,
+the Go side of the round trip with the ctx-shared-lua fixture plugin.
+
+Access reports the read keys, as found in the shared context, in the
+x-go-shared-read header, then writes every kind of value under go_*
+keys and overwrites lua_string.  Log reports the read keys again, for
+the values Lua plugins set in the log phase.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	// the kong.ctx.shared keys to report
+	Read []string `json:"read"`
+}
+
+func New() interface{} {
+	return &Config{}
+}
+
+func main() {
+	// after ctx-shared-lua (1000) in every phase
+	server.StartServer(New, "0.1", 1)
+}
+
+// written in the access phase, for Lua to read back; only types the
+// PDK can carry: nil, bool, float64, string, []interface{} and
+// map[string]interface{}
+var values = map[string]interface{}{
+	"go_string":  "hello from go",
+	"go_number":  3.25,
+	"go_integer": 12.0,
+	"go_bool":    true,
+	"go_false":   false,
+	"go_array":   []interface{}{"x", 1.0, true},
+	"go_table":   map[string]interface{}{"a": "b", "n": 1.0},
+	"go_nested": map[string]interface{}{
+		"level1": map[string]interface{}{
+			"level2": []interface{}{"deep", map[string]interface{}{"level3": true}},
+		},
+	},
+	"go_nil":     nil,
+	"lua_string": "overwritten by go",
+}
+
+type seen struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	Error string      `json:"error,omitempty"`
+}
+
+// read describes the read keys as this side of the RPC boundary sees them.
+func (conf Config) read(kong *pdk.PDK) string {
+	report := make(map[string]seen, len(conf.Read))
+	for _, k := range conf.Read {
+		v, err := kong.Ctx.GetSharedAny(k)
+		s := seen{Type: fmt.Sprintf("%T", v), Value: v}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		report[k] = s
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+	return string(b)
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	kong.Response.SetHeader("x-go-shared-read", conf.read(kong))
+
+	for k, v := range values {
+		if err := kong.Ctx.SetShared(k, v); err != nil {
+			kong.Log.Err("go-shared-ctx: setting ", k, ": ", err.Error())
+		}
+	}
+}
+
+func (conf Config) Log(kong *pdk.PDK) {
+	kong.Log.Notice("go-shared-ctx log phase: ", conf.read(kong))
+}
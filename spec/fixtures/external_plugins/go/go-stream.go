@@ -0,0 +1,57 @@
+/*
+A stream plugin in Go, for TCP and TLS routes,
+which logs where connections come from and rejects the denied ones.
+*/
+package main
+
+import (
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	DenyIps  []string `json:"deny_ips"`
+	DenySnis []string `json:"deny_snis"`
+}
+
+func New() interface{} {
+	return &Config{}
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (conf Config) Preread(kong *pdk.PDK) {
+	ip, err := kong.Client.GetIp()
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+	port, err := kong.Client.GetPort()
+	if err != nil {
+		kong.Log.Err(err.Error())
+	}
+
+	// set when Kong terminates TLS, otherwise peeked from the ClientHello
+	// of tls_passthrough routes; empty for plain TCP
+	sni, _ := kong.Nginx.GetVar("ssl_server_name")
+	if sni == "" {
+		sni, _ = kong.Nginx.GetVar("ssl_preread_server_name")
+	}
+
+	kong.Log.Notice("go-stream preread: client=", ip, ":", port, " sni=", sni)
+
+	if contains(conf.DenyIps, ip) || (sni != "" && contains(conf.DenySnis, sni)) {
+		kong.Log.Notice("go-stream rejecting client=", ip, " sni=", sni)
+		kong.Response.ExitStatus(403)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+A request transformer in Go,
+which short-circuits requests or rewrites what is sent upstream.
+*/
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+)
+
+type Config struct {
+	// with a status, ends the request from the access phase
+	Exit Exit `json:"exit"`
+
+	// replaces the upstream request body
+	Body string `json:"body"`
+	// fields added to a JSON upstream request body
+	Inject map[string]string `json:"inject"`
+
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Query  map[string]string `json:"query"`
+}
+
+type Exit struct {
+	Status  int               `json:"status"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+}
+
+func New() interface{} {
+	return &Config{}
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	if conf.Exit.Status != 0 {
+		headers := map[string][]string{}
+		for k, v := range conf.Exit.Headers {
+			headers[k] = []string{v}
+		}
+		kong.Response.Exit(conf.Exit.Status, []byte(conf.Exit.Body), headers)
+		return
+	}
+
+	if conf.Method != "" {
+		if err := kong.ServiceRequest.SetMethod(conf.Method); err != nil {
+			kong.Log.Err(err.Error())
+		}
+	}
+	if conf.Path != "" {
+		if err := kong.ServiceRequest.SetPath(conf.Path); err != nil {
+			kong.Log.Err(err.Error())
+		}
+	}
+	if conf.Query != nil {
+		query := map[string][]string{}
+		for k, v := range conf.Query {
+			query[k] = []string{v}
+		}
+		if err := kong.ServiceRequest.SetQuery(query); err != nil {
+			kong.Log.Err(err.Error())
+		}
+	}
+
+	if conf.Body != "" {
+		if err := kong.ServiceRequest.SetRawBody(conf.Body); err != nil {
+			kong.Log.Err(err.Error())
+		}
+	}
+
+	if len(conf.Inject) > 0 {
+		conf.inject(kong)
+	}
+}
+
+// inject adds the configured fields to a JSON object body.  go-pdk has no
+// counterpart of kong.service.request.set_body, so the body is decoded and
+// encoded here and sent with SetRawBody.
+func (conf Config) inject(kong *pdk.PDK) {
+	raw, err := kong.Request.GetRawBody()
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+
+	body := map[string]interface{}{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal([]byte(raw), &body); err != nil {
+			kong.Response.Exit(400, []byte(`{"message":"go-transform: body is not a JSON object"}`), map[string][]string{
+				"content-type": {"application/json"},
+			})
+			return
+		}
+	}
+
+	for k, v := range conf.Inject {
+		body[k] = v
+	}
+
+	out, err := json.Marshal(body)
+	if err != nil {
+		kong.Log.Err(err.Error())
+		return
+	}
+
+	if err := kong.ServiceRequest.SetRawBody(string(out)); err != nil {
+		kong.Log.Err(err.Error())
+	}
+	if err := kong.ServiceRequest.SetHeader("content-type", "application/json"); err != nil {
+		kong.Log.Err(err.Error())
+	}
+}
@@ -0,0 +1,179 @@
+/*
+A body validation plugin in Go,
+which checks JSON request bodies against a JSON Schema before proxying.
+
+The schema is given inline, as a JSON string, and validated with
+santhosh-tekuri/jsonschema as a draft 2020-12 schema unless its $schema
+says otherwise, formats included.  $ref can only point within the schema.
+Invalid schemas fail every request with a 500.  Failing bodies get a 400
+listing every failing location as a JSON Pointer:
+
+	{"message": "...", "errors": [{"path": "/items/3/id", "error": "..."}]}
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+type Config struct {
+	Schema string `json:"schema"`
+}
+
+func New() interface{} {
+	return &Config{Schema: "{}"}
+}
+
+func main() {
+	server.StartServer(New, "0.1", 1)
+}
+
+var (
+	schemasMu sync.Mutex
+	schemas   = map[string]*jsonschema.Schema{}
+)
+
+// getSchema compiles a schema once for all instances using it.  Schemas
+// are checked against their metaschema, and can't refer to others.
+func getSchema(src string) (*jsonschema.Schema, error) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+
+	if s, ok := schemas[src]; ok {
+		return s, nil
+	}
+
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	c.AssertFormat = true
+	c.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("%s: only references within the schema are supported", url)
+	}
+	if err := c.AddResource("schema.json", strings.NewReader(src)); err != nil {
+		return nil, err
+	}
+	s, err := c.Compile("schema.json")
+	if err != nil {
+		return nil, err
+	}
+
+	schemas[src] = s
+	return s, nil
+}
+
+type validationError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// validationErrors lists the innermost errors of err, by path.
+func validationErrors(err *jsonschema.ValidationError) []validationError {
+	var list []validationError
+
+	var walk func(err *jsonschema.ValidationError)
+	walk = func(err *jsonschema.ValidationError) {
+		if len(err.Causes) > 0 {
+			for _, cause := range err.Causes {
+				walk(cause)
+			}
+			return
+		}
+
+		path := err.InstanceLocation
+		if path == "" {
+			path = "/"
+		}
+		list = append(list, validationError{Path: path, Error: err.Message})
+	}
+	walk(err)
+
+	// stable, whatever order the keywords were checked in
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
+
+func reject(kong *pdk.PDK, status int, message string, errors []validationError) {
+	body, _ := json.Marshal(struct {
+		Message string            `json:"message"`
+		Errors  []validationError `json:"errors,omitempty"`
+	}{message, errors})
+
+	kong.Response.Exit(status, body, map[string][]string{
+		"content-type": {"application/json"},
+	})
+}
+
+// hasBody tells if the request comes with a body, from its framing.
+func hasBody(kong *pdk.PDK) bool {
+	if te, _ := kong.Request.GetHeader("transfer-encoding"); te != "" {
+		return true
+	}
+	cl, _ := kong.Request.GetHeader("content-length")
+	return cl != "" && cl != "0"
+}
+
+func (conf Config) Access(kong *pdk.PDK) {
+	s, err := getSchema(conf.Schema)
+	if err != nil {
+		kong.Log.Err("go-validator: invalid schema: ", err.Error())
+		reject(kong, 500, "invalid schema", nil)
+		return
+	}
+
+	contentType, _ := kong.Request.GetHeader("content-type")
+	// nothing to validate on GET, HEAD, DELETE...
+	if contentType == "" && !hasBody(kong) {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		reject(kong, 415, "request body must be JSON", nil)
+		return
+	}
+
+	raw, err := kong.Request.GetRawBody()
+	if err != nil {
+		kong.Log.Err("go-validator: reading body: ", err.Error())
+		reject(kong, 500, "could not read request body", nil)
+		return
+	}
+
+	var body interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		reject(kong, 400, "request body is not valid JSON", []validationError{{Path: "/", Error: err.Error()}})
+		return
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		reject(kong, 400, "request body is not valid JSON", []validationError{{Path: "/", Error: "trailing data"}})
+		return
+	}
+
+	if err := s.Validate(body); err != nil {
+		var invalid *jsonschema.ValidationError
+		if !errors.As(err, &invalid) {
+			kong.Log.Err("go-validator: validating body: ", err.Error())
+			reject(kong, 500, "could not validate request body", nil)
+			return
+		}
+		reject(kong, 400, "request body does not match the schema", validationErrors(invalid))
+		return
+	}
+
+	kong.ServiceRequest.SetHeader("x-body-validated", "true")
+}
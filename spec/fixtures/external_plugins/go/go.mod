@@ -2,9 +2,10 @@ module go-plugins
 
 go 1.21
 
-require github.com/Kong/go-pdk v0.11.1
-
 require (
-	github.com/ugorji/go/codec v1.2.14 // indirect
-	google.golang.org/protobuf v1.36.2 // indirect
+	github.com/Kong/go-pdk v0.11.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	google.golang.org/protobuf v1.36.2
 )
+
+require github.com/ugorji/go/codec v1.2.14 // indirect
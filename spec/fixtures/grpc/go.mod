@@ -0,0 +1,3 @@
+module protos
+
+go 1.16
@@ -0,0 +1,8 @@
+// Package protos embeds the .proto sources of this directory, so that
+// binaries built from them can write out the exact files they serve.
+package protos
+
+import "embed"
+
+//go:embed *.proto google
+var Sources embed.FS
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// channelzTree is the whole channelz state of a process, as written by
+// `target channelz`: every server with its sockets, and every top channel
+// with its subchannels, nested channels and sockets.
+type channelzTree struct {
+	Servers  []*channelzServer  `json:"servers"`
+	Channels []*channelzChannel `json:"channels"`
+}
+
+type channelzServer struct {
+	Server        json.RawMessage   `json:"server"`
+	ListenSockets []json.RawMessage `json:"listen_sockets"`
+	Sockets       []json.RawMessage `json:"sockets"`
+}
+
+type channelzChannel struct {
+	Channel     json.RawMessage    `json:"channel"`
+	Channels    []*channelzChannel `json:"channels,omitempty"`
+	Subchannels []*channelzChannel `json:"subchannels,omitempty"`
+	Sockets     []json.RawMessage  `json:"sockets,omitempty"`
+}
+
+// channelzDumper walks the channelz service of one process.
+type channelzDumper struct {
+	ctx    context.Context
+	client channelzpb.ChannelzClient
+}
+
+func marshalChannelz(m proto.Message) (json.RawMessage, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+}
+
+func (d *channelzDumper) socket(id int64) (json.RawMessage, error) {
+	resp, err := d.client.GetSocket(d.ctx, &channelzpb.GetSocketRequest{SocketId: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalChannelz(resp.GetSocket())
+}
+
+func (d *channelzDumper) sockets(refs []*channelzpb.SocketRef) ([]json.RawMessage, error) {
+	var sockets []json.RawMessage
+	for _, ref := range refs {
+		socket, err := d.socket(ref.GetSocketId())
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, socket)
+	}
+
+	return sockets, nil
+}
+
+func (d *channelzDumper) server(s *channelzpb.Server) (*channelzServer, error) {
+	out := &channelzServer{}
+
+	var err error
+	if out.Server, err = marshalChannelz(s); err != nil {
+		return nil, err
+	}
+	if out.ListenSockets, err = d.sockets(s.GetListenSocket()); err != nil {
+		return nil, err
+	}
+
+	for start := int64(0); ; {
+		resp, err := d.client.GetServerSockets(d.ctx, &channelzpb.GetServerSocketsRequest{
+			ServerId:      s.GetRef().GetServerId(),
+			StartSocketId: start,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		refs := resp.GetSocketRef()
+		sockets, err := d.sockets(refs)
+		if err != nil {
+			return nil, err
+		}
+		out.Sockets = append(out.Sockets, sockets...)
+
+		if resp.GetEnd() || len(refs) == 0 {
+			break
+		}
+		start = refs[len(refs)-1].GetSocketId() + 1
+	}
+
+	return out, nil
+}
+
+// channel dumps a channel or, with its subchannel refs, a subchannel.
+func (d *channelzDumper) channel(m proto.Message, channels []*channelzpb.ChannelRef, subchannels []*channelzpb.SubchannelRef, sockets []*channelzpb.SocketRef) (*channelzChannel, error) {
+	out := &channelzChannel{}
+
+	var err error
+	if out.Channel, err = marshalChannelz(m); err != nil {
+		return nil, err
+	}
+
+	for _, ref := range channels {
+		resp, err := d.client.GetChannel(d.ctx, &channelzpb.GetChannelRequest{ChannelId: ref.GetChannelId()})
+		if err != nil {
+			return nil, err
+		}
+
+		ch := resp.GetChannel()
+		nested, err := d.channel(ch, ch.GetChannelRef(), ch.GetSubchannelRef(), ch.GetSocketRef())
+		if err != nil {
+			return nil, err
+		}
+		out.Channels = append(out.Channels, nested)
+	}
+
+	for _, ref := range subchannels {
+		resp, err := d.client.GetSubchannel(d.ctx, &channelzpb.GetSubchannelRequest{SubchannelId: ref.GetSubchannelId()})
+		if err != nil {
+			return nil, err
+		}
+
+		sub := resp.GetSubchannel()
+		nested, err := d.channel(sub, sub.GetChannelRef(), sub.GetSubchannelRef(), sub.GetSocketRef())
+		if err != nil {
+			return nil, err
+		}
+		out.Subchannels = append(out.Subchannels, nested)
+	}
+
+	if out.Sockets, err = d.sockets(sockets); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (d *channelzDumper) tree() (*channelzTree, error) {
+	tree := &channelzTree{
+		Servers:  []*channelzServer{},
+		Channels: []*channelzChannel{},
+	}
+
+	for start := int64(0); ; {
+		resp, err := d.client.GetServers(d.ctx, &channelzpb.GetServersRequest{StartServerId: start})
+		if err != nil {
+			return nil, err
+		}
+
+		servers := resp.GetServer()
+		for _, s := range servers {
+			server, err := d.server(s)
+			if err != nil {
+				return nil, err
+			}
+			tree.Servers = append(tree.Servers, server)
+		}
+
+		if resp.GetEnd() || len(servers) == 0 {
+			break
+		}
+		start = servers[len(servers)-1].GetRef().GetServerId() + 1
+	}
+
+	for start := int64(0); ; {
+		resp, err := d.client.GetTopChannels(d.ctx, &channelzpb.GetTopChannelsRequest{StartChannelId: start})
+		if err != nil {
+			return nil, err
+		}
+
+		channels := resp.GetChannel()
+		for _, ch := range channels {
+			channel, err := d.channel(ch, ch.GetChannelRef(), ch.GetSubchannelRef(), ch.GetSocketRef())
+			if err != nil {
+				return nil, err
+			}
+			tree.Channels = append(tree.Channels, channel)
+		}
+
+		if resp.GetEnd() || len(channels) == 0 {
+			break
+		}
+		start = channels[len(channels)-1].GetRef().GetChannelId() + 1
+	}
+
+	return tree, nil
+}
+
+// runChannelz implements `target channelz`, which prints as JSON the
+// channelz tree of a running target: its listeners, the connections
+// accepted on them with their call counters, and its own client
+// channels such as the REST gateway's.
+func runChannelz(args []string) error {
+	fs := flag.NewFlagSet("channelz", flag.ExitOnError)
+	addr := fs.String("addr", "localhost"+port, "address of the target's admin services")
+	timeout := fs.Duration("timeout", 5*time.Second, "deadline of the whole dump")
+	fs.BoolVar(proxyProto, "proxy-protocol", false, "send a PROXY protocol header, for a target started with -proxy-protocol")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, *addr, grpc.WithInsecure(), grpc.WithContextDialer(dialProxied))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	d := &channelzDumper{ctx: ctx, client: channelzpb.NewChannelzClient(conn)}
+	tree, err := d.tree()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(tree)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/admin"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestChannelzTree(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	if _, err := admin.Register(s); err != nil {
+		t.Fatalf("admin.Register: %v", err)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	d := &channelzDumper{ctx: context.Background(), client: channelzpb.NewChannelzClient(conn)}
+	tree, err := d.tree()
+	if err != nil {
+		t.Fatalf("tree: %v", err)
+	}
+
+	// other tests' servers may show up too, as channelz is process-wide
+	if len(tree.Servers) == 0 {
+		t.Fatalf("got no servers")
+	}
+	if len(tree.Channels) == 0 {
+		t.Fatalf("got no channels, want at least the dumper's own")
+	}
+
+	sockets := 0
+	for _, server := range tree.Servers {
+		sockets += len(server.Sockets)
+	}
+	if sockets == 0 {
+		t.Errorf("got no server sockets, want at least the dumper's connection")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	cpb "target/chatservice"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// events queued for a member before it is dropped as too slow
+	chatBacklog = 64
+)
+
+type chatMember struct {
+	sender  string
+	events  chan *cpb.ChatEvent
+	evicted chan struct{}
+}
+
+type chatRoom struct {
+	seq     uint64
+	members map[*chatMember]struct{}
+}
+
+type chatServer struct {
+	cpb.UnimplementedChatServer
+
+	heartbeat time.Duration
+
+	mu    sync.Mutex
+	rooms map[string]*chatRoom
+}
+
+func newChatServer(heartbeat time.Duration) *chatServer {
+	return &chatServer{
+		heartbeat: heartbeat,
+		rooms:     map[string]*chatRoom{},
+	}
+}
+
+// publish sends an event to every member of the room but skip, and
+// returns it.  Members too slow to take it are evicted, and the room goes
+// away with the last of them.  Must be called with s.mu held.
+func (s *chatServer) publish(name string, skip *chatMember, kind cpb.ChatEvent_Kind, sender, text string) *cpb.ChatEvent {
+	room := s.rooms[name]
+	room.seq++
+
+	ev := &cpb.ChatEvent{
+		Kind:   kind,
+		Seq:    room.seq,
+		Room:   name,
+		Sender: sender,
+		Text:   text,
+		Time:   timestamppb.Now(),
+	}
+
+	for m := range room.members {
+		if m == skip {
+			continue
+		}
+
+		select {
+		case m.events <- ev:
+		default:
+			delete(room.members, m)
+			close(m.evicted)
+		}
+	}
+	if len(room.members) == 0 {
+		delete(s.rooms, name)
+	}
+
+	return ev
+}
+
+func (s *chatServer) join(name string, m *chatMember) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[name]
+	if !ok {
+		room = &chatRoom{members: map[*chatMember]struct{}{}}
+		s.rooms[name] = room
+	}
+	room.members[m] = struct{}{}
+
+	// the joining member is told too, so it knows when it is in
+	s.publish(name, nil, cpb.ChatEvent_JOINED, m.sender, "")
+}
+
+func (s *chatServer) leave(name string, m *chatMember) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[name]
+	if !ok {
+		return
+	}
+
+	if _, ok := room.members[m]; ok {
+		delete(room.members, m)
+		s.publish(name, m, cpb.ChatEvent_LEFT, m.sender, "")
+	}
+
+	if len(room.members) == 0 {
+		delete(s.rooms, name)
+	}
+}
+
+func (s *chatServer) say(name string, m *chatMember, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if room, ok := s.rooms[name]; ok {
+		if _, ok := room.members[m]; ok {
+			s.publish(name, m, cpb.ChatEvent_MESSAGE, m.sender, text)
+		}
+	}
+}
+
+func (s *chatServer) heartbeatEvent(name string) *cpb.ChatEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var seq uint64
+	if room, ok := s.rooms[name]; ok {
+		seq = room.seq
+	}
+
+	return &cpb.ChatEvent{
+		Kind: cpb.ChatEvent_HEARTBEAT,
+		Seq:  seq,
+		Room: name,
+		Time: timestamppb.Now(),
+	}
+}
+
+func (s *chatServer) Talk(stream cpb.Chat_TalkServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	name := first.GetRoom()
+	if name == "" {
+		return status.Error(codes.InvalidArgument, "the first message must name a room")
+	}
+
+	m := &chatMember{
+		sender:  first.GetSender(),
+		events:  make(chan *cpb.ChatEvent, chatBacklog),
+		evicted: make(chan struct{}),
+	}
+	s.join(name, m)
+	defer s.leave(name, m)
+
+	if first.GetText() != "" {
+		s.say(name, m, first.GetText())
+	}
+
+	heartbeat := s.heartbeat
+	if first.GetHeartbeatMs() > 0 {
+		heartbeat = time.Duration(first.GetHeartbeatMs()) * time.Millisecond
+	}
+
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	received := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				received <- err
+				return
+			}
+			s.say(name, m, msg.GetText())
+		}
+	}()
+
+	for {
+		select {
+		case ev := <-m.events:
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+
+		case <-tick:
+			if err := stream.Send(s.heartbeatEvent(name)); err != nil {
+				return err
+			}
+
+		case err := <-received:
+			if err != io.EOF {
+				return err
+			}
+
+			// the client is done sending, not receiving: hand over what
+			// was queued for it before going
+			s.leave(name, m)
+			for {
+				select {
+				case ev := <-m.events:
+					if err := stream.Send(ev); err != nil {
+						return err
+					}
+				default:
+					return nil
+				}
+			}
+
+		case <-m.evicted:
+			return status.Error(codes.ResourceExhausted, "too many undelivered chat events")
+
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
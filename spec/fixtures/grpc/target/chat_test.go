@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	cpb "target/chatservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dialChat serves cs in-process and returns a client of it.
+func dialChat(t *testing.T, cs *chatServer) cpb.ChatClient {
+	t.Helper()
+
+	s := grpc.NewServer()
+	cpb.RegisterChatServer(s, cs)
+
+	return cpb.NewChatClient(dialServer(t, s))
+}
+
+// talk joins room as sender and waits until it is in.
+func talk(t *testing.T, client cpb.ChatClient, room, sender string, heartbeatMs uint32) cpb.Chat_TalkClient {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stream, err := client.Talk(ctx)
+	if err != nil {
+		t.Fatalf("Talk: %v", err)
+	}
+	if err := stream.Send(&cpb.ChatMessage{Room: room, Sender: sender, HeartbeatMs: heartbeatMs}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	for {
+		ev := recvEvent(t, stream)
+		if ev.GetKind() == cpb.ChatEvent_JOINED && ev.GetSender() == sender {
+			return stream
+		}
+	}
+}
+
+func recvEvent(t *testing.T, stream cpb.Chat_TalkClient) *cpb.ChatEvent {
+	t.Helper()
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+
+	return ev
+}
+
+func expectEvent(t *testing.T, stream cpb.Chat_TalkClient, kind cpb.ChatEvent_Kind, seq uint64, sender, text string) {
+	t.Helper()
+
+	ev := recvEvent(t, stream)
+	if ev.GetKind() != kind || ev.GetSeq() != seq || ev.GetSender() != sender || ev.GetText() != text {
+		t.Errorf("got %v #%d from %q: %q, want %v #%d from %q: %q",
+			ev.GetKind(), ev.GetSeq(), ev.GetSender(), ev.GetText(), kind, seq, sender, text)
+	}
+}
+
+func TestChatBroadcast(t *testing.T) {
+	client := dialChat(t, newChatServer(0))
+
+	alice := talk(t, client, "lobby", "alice", 0)
+	bob := talk(t, client, "lobby", "bob", 0)
+	carol := talk(t, client, "elsewhere", "carol", 0)
+
+	// alice sees bob joining, with the next sequence number
+	expectEvent(t, alice, cpb.ChatEvent_JOINED, 2, "bob", "")
+
+	if err := bob.Send(&cpb.ChatMessage{Text: "hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := alice.Send(&cpb.ChatMessage{Text: "hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	// the order between both messages is up to the server; each member
+	// only gets the other one's message
+	for _, stream := range []cpb.Chat_TalkClient{alice, bob} {
+		ev := recvEvent(t, stream)
+		if ev.GetKind() != cpb.ChatEvent_MESSAGE || ev.GetSeq() < 3 || ev.GetSeq() > 4 {
+			t.Errorf("got %v #%d, want a MESSAGE #3 or #4", ev.GetKind(), ev.GetSeq())
+		}
+		if stream == alice && ev.GetText() != "hi" || stream == bob && ev.GetText() != "hello" {
+			t.Errorf("got %q from %q", ev.GetText(), ev.GetSender())
+		}
+	}
+
+	// other rooms hear nothing, and count on their own
+	dave := talk(t, client, "elsewhere", "dave", 0)
+	expectEvent(t, carol, cpb.ChatEvent_JOINED, 2, "dave", "")
+	if err := dave.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	expectEvent(t, carol, cpb.ChatEvent_LEFT, 3, "dave", "")
+}
+
+func TestChatFirstMessage(t *testing.T) {
+	client := dialChat(t, newChatServer(0))
+
+	alice := talk(t, client, "lobby", "alice", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Talk(ctx)
+	if err != nil {
+		t.Fatalf("Talk: %v", err)
+	}
+	if err := stream.Send(&cpb.ChatMessage{Room: "lobby", Sender: "bob", Text: "hi all"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	expectEvent(t, alice, cpb.ChatEvent_JOINED, 2, "bob", "")
+	expectEvent(t, alice, cpb.ChatEvent_MESSAGE, 3, "bob", "hi all")
+
+	stream, err = client.Talk(ctx)
+	if err != nil {
+		t.Fatalf("Talk: %v", err)
+	}
+	if err := stream.Send(&cpb.ChatMessage{Sender: "nobody"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v, want code %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestChatHeartbeat(t *testing.T) {
+	client := dialChat(t, newChatServer(time.Hour))
+
+	// the server default is overridden when joining
+	talk(t, client, "lobby", "bob", 0)
+	alice := talk(t, client, "lobby", "alice", 20)
+
+	// heartbeats carry the last sequence number of the room
+	for i := 0; i < 2; i++ {
+		expectEvent(t, alice, cpb.ChatEvent_HEARTBEAT, 2, "", "")
+	}
+}
+
+func TestChatLeave(t *testing.T) {
+	cs := newChatServer(0)
+	client := dialChat(t, cs)
+
+	alice := talk(t, client, "lobby", "alice", 0)
+	bob := talk(t, client, "lobby", "bob", 0)
+	expectEvent(t, alice, cpb.ChatEvent_JOINED, 2, "bob", "")
+
+	if err := bob.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	expectEvent(t, alice, cpb.ChatEvent_LEFT, 3, "bob", "")
+
+	// rooms go away with their last member
+	if err := alice.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	if _, err := alice.Recv(); err == nil {
+		t.Fatalf("alice still receives after leaving")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		cs.mu.Lock()
+		rooms := len(cs.rooms)
+		cs.mu.Unlock()
+
+		if rooms == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d rooms left after everyone left", rooms)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChatEviction(t *testing.T) {
+	cs := newChatServer(0)
+
+	// a member that can't take a single event, not even its own join
+	m := &chatMember{sender: "alice", events: make(chan *cpb.ChatEvent), evicted: make(chan struct{})}
+	cs.join("lobby", m)
+
+	select {
+	case <-m.evicted:
+	default:
+		t.Fatalf("alice wasn't evicted")
+	}
+	if len(cs.rooms) != 0 {
+		t.Errorf("%d rooms left after evicting everyone", len(cs.rooms))
+	}
+}
+
+func TestChatDrainOnClose(t *testing.T) {
+	client := dialChat(t, newChatServer(0))
+
+	alice := talk(t, client, "lobby", "alice", 0)
+	bob := talk(t, client, "lobby", "bob", 0)
+	carol := talk(t, client, "lobby", "carol", 0)
+	expectEvent(t, alice, cpb.ChatEvent_JOINED, 2, "bob", "")
+	expectEvent(t, alice, cpb.ChatEvent_JOINED, 3, "carol", "")
+	expectEvent(t, bob, cpb.ChatEvent_JOINED, 3, "carol", "")
+
+	const messages = 10
+	for i := 0; i < messages; i++ {
+		if err := alice.Send(&cpb.ChatMessage{Text: "hi"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	// carol got them all, so they are queued for bob too
+	for i := 0; i < messages; i++ {
+		expectEvent(t, carol, cpb.ChatEvent_MESSAGE, uint64(4+i), "alice", "hi")
+	}
+
+	if err := bob.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	for i := 0; i < messages; i++ {
+		expectEvent(t, bob, cpb.ChatEvent_MESSAGE, uint64(4+i), "alice", "hi")
+	}
+	if _, err := bob.Recv(); err != io.EOF {
+		t.Errorf("got %v after the queued events, want EOF", err)
+	}
+}
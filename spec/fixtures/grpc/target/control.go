@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// newControlMux serves the runtime controls of the target:
+//
+//	GET /instances                            list the instances and their health
+//	PUT /instances/{id}/health?status=STATUS  change the health of an instance
+//	POST /scenario/reload                     read the scenario file again
+func newControlMux(instances []*instance, sc *scenario) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/scenario/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := sc.reload(); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		sc.mu.RLock()
+		defer sc.mu.RUnlock()
+		writeJSON(w, http.StatusOK, map[string]int{"rules": len(sc.rules)})
+	})
+
+	mux.HandleFunc("/instances", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		type instanceInfo struct {
+			ID      string `json:"id"`
+			Addr    string `json:"addr"`
+			Health  string `json:"health"`
+			Latency string `json:"latency"`
+			Jitter  string `json:"jitter"`
+		}
+
+		infos := []instanceInfo{}
+		for _, in := range instances {
+			infos = append(infos, instanceInfo{
+				ID:      in.id,
+				Addr:    in.addr,
+				Health:  in.healthStatus().String(),
+				Latency: in.latency.String(),
+				Jitter:  in.jitter.String(),
+			})
+		}
+
+		writeJSON(w, http.StatusOK, infos)
+	})
+
+	mux.HandleFunc("/instances/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/instances/"), "/")
+		if len(parts) != 2 || parts[1] != "health" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		for _, in := range instances {
+			if in.id != parts[0] {
+				continue
+			}
+
+			if err := in.setHealth(r.URL.Query().Get("status")); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("instance %s is now %v", in.id, in.healthStatus())
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		http.NotFound(w, r)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
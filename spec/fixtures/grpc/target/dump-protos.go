@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"protos"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	protosetName = "target.protoset"
+)
+
+// servedFiles returns the files declaring the services registered on s,
+// along with all their imports, dependencies first.
+func servedFiles(s *grpc.Server) ([]protoreflect.FileDescriptor, error) {
+	var names []string
+	for name := range s.GetServiceInfo() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files []protoreflect.FileDescriptor
+	seen := map[string]bool{}
+
+	var visit func(fd protoreflect.FileDescriptor)
+	visit = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			visit(imports.Get(i).FileDescriptor)
+		}
+		files = append(files, fd)
+	}
+
+	for _, name := range names {
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, err
+		}
+		visit(d.ParentFile())
+	}
+
+	return files, nil
+}
+
+// dumpProtos writes into dir the .proto sources of every service served
+// by s, and a FileDescriptorSet of them named target.protoset.
+func dumpProtos(s *grpc.Server, dir string) error {
+	files, err := servedFiles(s)
+	if err != nil {
+		return err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range files {
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+
+		src, err := fs.ReadFile(protos.Sources, fd.Path())
+		if err != nil {
+			// descriptors compiled into dependencies, no source shipped
+			log.Printf("no source for %s, only in %s", fd.Path(), protosetName)
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(fd.Path()))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			return err
+		}
+	}
+
+	b, err := proto.Marshal(set)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, protosetName), b, 0644)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// request header choosing a fault for that call only
+	faultHeader = "x-target-fault"
+)
+
+type faultConnKey struct{}
+
+// faultConn counts the HEADERS frames written to a connection, so that
+// faults can wait for their response headers to be sent.
+type faultConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	headers int
+	wrote   chan struct{}
+	// frame parsing state: a partial frame header, or payload to skip
+	partial []byte
+	skip    int
+}
+
+func newFaultConn(conn net.Conn) *faultConn {
+	return &faultConn{Conn: conn, wrote: make(chan struct{})}
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for p := b[:n]; len(p) > 0; {
+		if c.skip > 0 {
+			skipped := c.skip
+			if skipped > len(p) {
+				skipped = len(p)
+			}
+			c.skip -= skipped
+			p = p[skipped:]
+			continue
+		}
+
+		missing := 9 - len(c.partial)
+		if missing > len(p) {
+			missing = len(p)
+		}
+		c.partial = append(c.partial, p[:missing]...)
+		p = p[missing:]
+		if len(c.partial) < 9 {
+			break
+		}
+
+		fh := c.partial
+		c.skip = int(fh[0])<<16 | int(fh[1])<<8 | int(fh[2])
+		if http2.FrameType(fh[3]) == http2.FrameHeaders {
+			c.headers++
+			close(c.wrote)
+			c.wrote = make(chan struct{})
+		}
+		c.partial = c.partial[:0]
+	}
+
+	return n, err
+}
+
+func (c *faultConn) headersWritten() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.headers
+}
+
+// waitHeaders waits for a HEADERS frame after the first n ones, at most
+// timeout.
+func (c *faultConn) waitHeaders(n int, timeout time.Duration) {
+	deadline := time.After(timeout)
+	for {
+		c.mu.Lock()
+		headers, wrote := c.headers, c.wrote
+		c.mu.Unlock()
+
+		if headers > n {
+			return
+		}
+
+		select {
+		case <-wrote:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// faults are HTTP/2 level misbehaviours that grpc-go never produces.  They
+// are only served by the -faults listener, chosen per call by the
+// x-target-fault request header or per method by the `fault` of a
+// scenario rule.
+var faults = map[string]http.HandlerFunc{
+	// response headers and part of a message, then RST_STREAM
+	"rst_stream": func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte{0, 0, 0, 0, 32, 0x0a, 0x02})
+		w.(http.Flusher).Flush()
+
+		// makes the HTTP/2 server reset the stream
+		panic(http.ErrAbortHandler)
+	},
+
+	// a HEADERS frame ending the stream, without grpc-status
+	"trailers_only_no_status": func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+	},
+
+	// a length prefix announcing more bytes than sent, then an OK status
+	"truncated_frame": func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte{0, 0, 0, 0, 100, 0x0a, 0x02, 'h', 'i'})
+		w.Header().Set("Grpc-Status", "0")
+	},
+
+	// a successful response that isn't gRPC at all
+	"wrong_content_type": func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("<html><body>not grpc</body></html>"))
+	},
+
+	// the whole connection is closed before any response
+	"close_before_headers": func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := r.Context().Value(faultConnKey{}).(*faultConn)
+		if !ok {
+			noFaultConn(w)
+			return
+		}
+		conn.Close()
+	},
+
+	// response headers, then the whole connection is closed
+	"close_after_headers": func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := r.Context().Value(faultConnKey{}).(*faultConn)
+		if !ok {
+			noFaultConn(w)
+			return
+		}
+		written := conn.headersWritten()
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		// Flush only queues the frame for the connection's writer
+		conn.waitHeaders(written, time.Second)
+		conn.Close()
+	},
+}
+
+// noFaultConn fails calls asking for connection faults on connections
+// serveFaultConns didn't accept, with a trailers-only gRPC error.
+func noFaultConn(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(codes.Internal)))
+	w.Header().Set("Grpc-Message", "connection faults need the faults listener")
+	w.WriteHeader(http.StatusOK)
+}
+
+// faultHandler serves the calls without a fault through grpc-go, so that
+// the faults listener otherwise behaves like the main one.
+type faultHandler struct {
+	grpc     *grpc.Server
+	scenario *scenario
+}
+
+func (h *faultHandler) fault(r *http.Request) string {
+	if name := r.Header.Get(faultHeader); name != "" {
+		return name
+	}
+
+	md := metadata.MD{}
+	for k, v := range r.Header {
+		md[strings.ToLower(k)] = v
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	if rule := h.scenario.find(ctx, r.URL.Path, nil); rule != nil {
+		return rule.Response.Fault
+	}
+
+	return ""
+}
+
+func (h *faultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// grpc-go only sees r.RemoteAddr here, so report the PROXY addresses
+	// before it writes the response headers
+	if conn, ok := r.Context().Value(faultConnKey{}).(*faultConn); ok {
+		if addr, ok := conn.RemoteAddr().(*proxyAddr); ok {
+			for k, v := range addr.metadata() {
+				w.Header()[k] = v
+			}
+		}
+	}
+
+	name := h.fault(r)
+	if name == "" {
+		h.grpc.ServeHTTP(w, r)
+		return
+	}
+
+	fault, ok := faults[name]
+	if !ok {
+		http.Error(w, "unknown fault "+name, http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("injecting %s fault into %s", name, r.URL.Path)
+	fault(w, r)
+}
+
+// serveFaults accepts cleartext HTTP/2 (prior knowledge, as Kong talks
+// to grpc:// upstreams) on addr, with golang.org/x/net/http2 instead of
+// grpc-go's own transport.
+func serveFaults(addr string, s *grpc.Server, sc *scenario) {
+	lis, err := listen(addr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	log.Printf("faults listening at %v", lis.Addr())
+
+	if err := serveFaultConns(lis, s, sc); err != nil {
+		log.Fatalf("failed to accept: %v", err)
+	}
+}
+
+func serveFaultConns(lis net.Listener, s *grpc.Server, sc *scenario) error {
+	h2 := &http2.Server{}
+	handler := &faultHandler{grpc: s, scenario: sc}
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+
+		fc := newFaultConn(conn)
+		go h2.ServeConn(fc, &http2.ServeConnOpts{
+			Context: context.WithValue(context.Background(), faultConnKey{}, fc),
+			Handler: handler,
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	pb "target/targetservice"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// listenFaults serves the Bouncer in-process through the faults handler,
+// following the scenario src, and returns the listener.
+func listenFaults(t *testing.T, src string) *bufconn.Listener {
+	t.Helper()
+
+	sc, _ := dialScenario(t, src)
+
+	s := grpc.NewServer()
+	pb.RegisterBouncerServer(s, &server{})
+
+	lis := bufconn.Listen(1 << 20)
+	go serveFaultConns(lis, s, sc)
+	t.Cleanup(func() { lis.Close() })
+
+	return lis
+}
+
+// rawFrames calls SayHello on lis with the fault header and describes the
+// frames of the response, up to the end of the stream or the connection.
+func rawFrames(t *testing.T, lis *bufconn.Listener, fault string) []string {
+	t.Helper()
+
+	conn, err := lis.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := io.WriteString(conn, http2.ClientPreface); err != nil {
+		t.Fatal(err)
+	}
+	framer := http2.NewFramer(conn, conn)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	for _, f := range [][2]string{
+		{":method", "POST"},
+		{":scheme", "http"},
+		{":path", "/targetservice.Bouncer/SayHello"},
+		{":authority", "bufnet"},
+		{"content-type", "application/grpc"},
+		{"te", "trailers"},
+		{faultHeader, fault},
+	} {
+		enc.WriteField(hpack.HeaderField{Name: f[0], Value: f[1]})
+	}
+	if err := framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: block.Bytes(),
+		EndHeaders:    true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// an empty HelloRequest
+	if err := framer.WriteData(1, true, []byte{0, 0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []string
+	for {
+		f, err := framer.ReadFrame()
+		if err == io.EOF {
+			return append(frames, "EOF")
+		}
+		if err != nil {
+			t.Fatalf("after %v: %v", frames, err)
+		}
+
+		var desc string
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				framer.WriteSettingsAck()
+			}
+			continue
+
+		case *http2.MetaHeadersFrame:
+			var fields []string
+			for _, hf := range f.RegularFields() {
+				if hf.Name == "content-type" || hf.Name == "grpc-status" {
+					fields = append(fields, hf.Name+"="+hf.Value)
+				}
+			}
+			desc = strings.Join(append([]string{"HEADERS"}, fields...), " ")
+
+		case *http2.DataFrame:
+			desc = "DATA " + strconv.Itoa(len(f.Data()))
+
+		case *http2.RSTStreamFrame:
+			return append(frames, "RST_STREAM "+f.ErrCode.String())
+
+		default:
+			continue
+		}
+
+		if f.Header().Flags.Has(http2.FlagDataEndStream) {
+			return append(frames, desc+" END_STREAM")
+		}
+		frames = append(frames, desc)
+	}
+}
+
+func TestFaultFrames(t *testing.T) {
+	lis := listenFaults(t, "")
+
+	for fault, want := range map[string][]string{
+		"rst_stream": {
+			"HEADERS content-type=application/grpc",
+			"DATA 7",
+			"RST_STREAM INTERNAL_ERROR",
+		},
+		"trailers_only_no_status": {
+			"HEADERS content-type=application/grpc END_STREAM",
+		},
+		"truncated_frame": {
+			"HEADERS content-type=application/grpc",
+			"DATA 9",
+			"HEADERS grpc-status=0 END_STREAM",
+		},
+		"wrong_content_type": {
+			"HEADERS content-type=text/html",
+			"DATA 34 END_STREAM",
+		},
+		"close_before_headers": {
+			"EOF",
+		},
+		"close_after_headers": {
+			"HEADERS content-type=application/grpc",
+			"EOF",
+		},
+	} {
+		if got := rawFrames(t, lis, fault); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", fault, got, want)
+		}
+	}
+}
+
+// what a gRPC client makes of the faults; none of them may hang
+func TestFaultCodes(t *testing.T) {
+	lis := listenFaults(t, `
+rules:
+  - method: /targetservice.Bouncer/Echo
+    response:
+      fault: close_after_headers
+`)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewBouncerClient(conn)
+
+	for fault, want := range map[string]codes.Code{
+		"":                        codes.OK,
+		"rst_stream":              codes.Internal,
+		"trailers_only_no_status": codes.Unknown,
+		"truncated_frame":         codes.Internal,
+		"wrong_content_type":      codes.Unknown,
+		"close_before_headers":    codes.Unavailable,
+		"close_after_headers":     codes.Unavailable,
+		"no_such_fault":           codes.Internal,
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx = metadata.AppendToOutgoingContext(ctx, faultHeader, fault)
+		_, err := client.SayHello(ctx, &pb.HelloRequest{Greeting: "john_doe"})
+		cancel()
+
+		if status.Code(err) != want {
+			t.Errorf("%q: got %v, want code %v", fault, err, want)
+		}
+	}
+
+	// chosen by the scenario
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Echo(ctx, &pb.EchoMsg{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want code %v", err, codes.Unavailable)
+	}
+}
+
+// connection faults served without the faultConn of serveFaultConns
+func TestFaultWithoutConn(t *testing.T) {
+	for _, name := range []string{"close_before_headers", "close_after_headers"} {
+		w := httptest.NewRecorder()
+		faults[name](w, httptest.NewRequest(http.MethodPost, "/targetservice.Bouncer/SayHello", nil))
+
+		if got := w.Header().Get("Grpc-Status"); got != strconv.Itoa(int(codes.Internal)) {
+			t.Errorf("%s: got grpc-status %q, want %d", name, got, codes.Internal)
+		}
+	}
+}
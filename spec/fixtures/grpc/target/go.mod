@@ -4,7 +4,13 @@ go 1.15
 
 require (
 	github.com/golang/protobuf v1.5.2
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.5.0
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
 	google.golang.org/genproto v0.0.0-20210716133855-ce7ef5c701ea
 	google.golang.org/grpc v1.39.0
 	google.golang.org/protobuf v1.27.1
+	protos v0.0.0
+	sigs.k8s.io/yaml v1.2.0
 )
+
+replace protos => ../
@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"os"
 	"time"
 
+	cpb "target/chatservice"
 	pb "target/targetservice"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/admin"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -17,6 +23,22 @@ const (
 	port = ":15010"
 )
 
+var (
+	instances instanceFlags
+
+	restAddr     = flag.String("rest", "", "also serve the google.api.http REST mappings on this address")
+	chatBeat     = flag.Duration("chat-heartbeat", 10*time.Second, "interval between chat heartbeats, 0 disables them")
+	dumpProtoDir = flag.String("dump-protos", "", "write the served .proto files and their descriptor set to this directory and exit")
+	controlAddr  = flag.String("control", "", "serve the runtime control endpoints on this address")
+	faultsAddr   = flag.String("faults", "", "also serve on this address through golang.org/x/net/http2, injecting the faults of faults.go")
+	scenarioPath = flag.String("scenario", "", "YAML or JSON scenario file overriding the handlers, see scenario.go")
+	proxyProto   = flag.Bool("proxy-protocol", false, "expect a PROXY protocol v1 or v2 header on every connection to the gRPC listeners")
+)
+
+func init() {
+	flag.Var(&instances, "instance", "also serve an upstream instance, as ID@ADDR[?latency=D&jitter=D&health=STATUS]; repeatable")
+}
+
 type server struct {
 	pb.UnimplementedBouncerServer
 }
@@ -53,14 +75,110 @@ func (s *server) Echo(ctx context.Context, in *pb.EchoMsg) (*pb.EchoMsg, error)
 	return in, nil
 }
 
-func main() {
-	lis, err := net.Listen("tcp", port)
+func (s *server) EchoStream(stream pb.Bouncer_EchoStreamServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(in); err != nil {
+			return err
+		}
+	}
+}
+
+func serveREST(addr string, target net.Addr) {
+	conn, err := grpc.Dial(target.String(), grpc.WithInsecure(), grpc.WithContextDialer(dialProxied))
+	if err != nil {
+		log.Fatalf("failed to dial %v: %v", target, err)
+	}
+
+	mux, err := newRESTGateway(conn)
+	if err != nil {
+		log.Fatalf("failed to build REST gateway: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	log.Printf("REST gateway listening at %v", lis.Addr())
+	if err := http.Serve(lis, mux); err != nil {
+		log.Fatalf("failed to serve REST: %v", err)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "load":
+			if err := runLoad(os.Args[2:]); err != nil {
+				log.Fatalf("load: %v", err)
+			}
+			return
+
+		case "channelz":
+			if err := runChannelz(os.Args[2:]); err != nil {
+				log.Fatalf("channelz: %v", err)
+			}
+			return
+		}
+	}
+
+	flag.Parse()
+
+	sc, err := newScenario(*scenarioPath)
+	if err != nil {
+		log.Fatalf("failed to load scenario: %v", err)
+	}
+
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(proxyUnaryInterceptor, sc.unaryInterceptor),
+		grpc.ChainStreamInterceptor(proxyStreamInterceptor, sc.streamInterceptor),
+	)
 	pb.RegisterBouncerServer(s, &server{})
+	cpb.RegisterChatServer(s, newChatServer(*chatBeat))
+
+	if *dumpProtoDir != "" {
+		if err := dumpProtos(s, *dumpProtoDir); err != nil {
+			log.Fatalf("failed to dump protos: %v", err)
+		}
+		return
+	}
+
+	// registered after the dump, which is only meant for the test services
+	if _, err := admin.Register(s); err != nil {
+		log.Fatalf("failed to register admin services: %v", err)
+	}
+
+	lis, err := listen(port)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
 	log.Printf("server listening at %v", lis.Addr())
+
+	if *restAddr != "" {
+		go serveREST(*restAddr, lis.Addr())
+	}
+
+	if *faultsAddr != "" {
+		go serveFaults(*faultsAddr, s, sc)
+	}
+
+	for _, in := range instances {
+		go in.serve(*chatBeat, sc)
+	}
+
+	if *controlAddr != "" {
+		go func() {
+			log.Printf("control endpoints listening at %v", *controlAddr)
+			log.Fatal(http.ListenAndServe(*controlAddr, newControlMux(instances, sc)))
+		}()
+	}
+
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	pb "target/targetservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// dialTarget serves the Bouncer in-process and returns a connection to it.
+func dialTarget(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	s := grpc.NewServer()
+	pb.RegisterBouncerServer(s, &server{})
+
+	return dialServer(t, s)
+}
+
+// dialServer serves s in-process and returns a connection to it.
+func dialServer(t *testing.T, s *grpc.Server) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestSayHello(t *testing.T) {
+	client := pb.NewBouncerClient(dialTarget(t))
+
+	for _, boolean := range []bool{false, true} {
+		resp, err := client.SayHello(context.Background(), &pb.HelloRequest{
+			Greeting:    "john_doe",
+			BooleanTest: boolean,
+		})
+		if err != nil {
+			t.Fatalf("SayHello: %v", err)
+		}
+
+		if resp.GetReply() != "hello john_doe" {
+			t.Errorf("got reply %q, want %q", resp.GetReply(), "hello john_doe")
+		}
+		if resp.GetBooleanTest() != boolean {
+			t.Errorf("got boolean_test %v, want %v", resp.GetBooleanTest(), boolean)
+		}
+	}
+}
+
+func TestBounceIt(t *testing.T) {
+	client := pb.NewBouncerClient(dialTarget(t))
+
+	now := time.Date(2021, 7, 16, 13, 38, 55, 500000000, time.UTC)
+	when := now.Add(-5*time.Minute - 15*time.Second)
+
+	resp, err := client.BounceIt(context.Background(), &pb.BallIn{
+		Message: "hi",
+		When:    timestamppb.New(when),
+		Now:     timestamppb.New(now),
+	})
+	if err != nil {
+		t.Fatalf("BounceIt: %v", err)
+	}
+
+	if resp.GetReply() != "hello hi" {
+		t.Errorf("got reply %q, want %q", resp.GetReply(), "hello hi")
+	}
+
+	// the difference is truncated to the second
+	want := "2021-07-16T13:33:40Z was 5m15s ago"
+	if resp.GetTimeMessage() != want {
+		t.Errorf("got time_message %q, want %q", resp.GetTimeMessage(), want)
+	}
+	if !resp.GetNow().AsTime().Equal(now) {
+		t.Errorf("got now %v, want %v", resp.GetNow().AsTime(), now)
+	}
+}
+
+func TestGrowTail(t *testing.T) {
+	client := pb.NewBouncerClient(dialTarget(t))
+
+	body := &pb.Body{
+		Name:  "dog",
+		Hands: &pb.Limb{Count: 0},
+		Legs:  &pb.Limb{Count: 4, Endings: "paws"},
+		Tail:  &pb.Limb{Count: 1, Endings: "fluffy"},
+	}
+
+	resp, err := client.GrowTail(context.Background(), body)
+	if err != nil {
+		t.Fatalf("GrowTail: %v", err)
+	}
+
+	if resp.GetTail().GetCount() != 2 {
+		t.Errorf("got %d tails, want 2", resp.GetTail().GetCount())
+	}
+
+	// everything else is bounced as is
+	want := proto.Clone(body).(*pb.Body)
+	want.Tail.Count = 2
+	if !proto.Equal(resp, want) {
+		t.Errorf("got %v, want %v", resp, want)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	client := pb.NewBouncerClient(dialTarget(t))
+
+	for _, msg := range []*pb.EchoMsg{
+		{},
+		{Array: []string{}, Nullable: ""},
+		{Array: []string{"a", "b", "c"}, Nullable: "ahaha"},
+	} {
+		resp, err := client.Echo(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Echo: %v", err)
+		}
+
+		if !proto.Equal(resp, msg) {
+			t.Errorf("got %v, want %v", resp, msg)
+		}
+	}
+}
+
+func TestEchoStream(t *testing.T) {
+	stream, err := pb.NewBouncerClient(dialTarget(t)).EchoStream(context.Background())
+	if err != nil {
+		t.Fatalf("EchoStream: %v", err)
+	}
+
+	msgs := []*pb.EchoMsg{
+		{},
+		{Array: []string{"a", "b", "c"}, Nullable: "ahaha"},
+		{Nullable: "last"},
+	}
+	for _, msg := range msgs {
+		if err := stream.Send(msg); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+
+	for _, msg := range msgs {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if !proto.Equal(resp, msg) {
+			t.Errorf("got %v, want %v", resp, msg)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("got %v after the echoes, want EOF", err)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	client := pb.NewBouncerClient(dialTarget(t))
+
+	_, err := client.UnknownMethod(context.Background(), &pb.HelloRequest{Greeting: "john_doe"})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("got %v, want code %v", err, codes.Unimplemented)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+	"strings"
+	"time"
+
+	cpb "target/chatservice"
+	pb "target/targetservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// sent in the response headers of every call made to an instance
+	instanceHeader = "x-instance-id"
+)
+
+// instance is one of the upstream backends a single target process
+// pretends to be, each on its own listener.
+type instance struct {
+	id      string
+	addr    string
+	latency time.Duration
+	jitter  time.Duration
+	health  *health.Server
+}
+
+// parseInstance reads an instance definition of the form
+// ID@ADDR[?latency=DURATION&jitter=DURATION&health=STATUS],
+// e.g. "b@:15022?latency=50ms&health=NOT_SERVING".
+func parseInstance(v string) (*instance, error) {
+	at := strings.Index(v, "@")
+	if at <= 0 {
+		return nil, fmt.Errorf("instance %q: expected ID@ADDR", v)
+	}
+
+	in := &instance{
+		id:     v[:at],
+		addr:   v[at+1:],
+		health: health.NewServer(),
+	}
+
+	if q := strings.Index(in.addr, "?"); q >= 0 {
+		params, err := url.ParseQuery(in.addr[q+1:])
+		if err != nil {
+			return nil, fmt.Errorf("instance %q: %w", in.id, err)
+		}
+		in.addr = in.addr[:q]
+
+		if d := params.Get("latency"); d != "" {
+			if in.latency, err = time.ParseDuration(d); err != nil {
+				return nil, fmt.Errorf("instance %q: %w", in.id, err)
+			}
+		}
+		if d := params.Get("jitter"); d != "" {
+			if in.jitter, err = time.ParseDuration(d); err != nil {
+				return nil, fmt.Errorf("instance %q: %w", in.id, err)
+			}
+		}
+		if h := params.Get("health"); h != "" {
+			if err := in.setHealth(h); err != nil {
+				return nil, fmt.Errorf("instance %q: %w", in.id, err)
+			}
+		}
+	}
+
+	return in, nil
+}
+
+// instanceFlags collects the repeated -instance flag.
+type instanceFlags []*instance
+
+func (f *instanceFlags) String() string {
+	var ids []string
+	for _, in := range *f {
+		ids = append(ids, in.id+"@"+in.addr)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func (f *instanceFlags) Set(v string) error {
+	in, err := parseInstance(v)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range *f {
+		if other.id == in.id {
+			return fmt.Errorf("instance %q defined twice", in.id)
+		}
+	}
+	*f = append(*f, in)
+
+	return nil
+}
+
+func (in *instance) setHealth(name string) error {
+	st, ok := healthpb.HealthCheckResponse_ServingStatus_value[strings.ToUpper(name)]
+	if !ok {
+		return fmt.Errorf("unknown health status %q", name)
+	}
+	in.health.SetServingStatus("", healthpb.HealthCheckResponse_ServingStatus(st))
+
+	return nil
+}
+
+func (in *instance) healthStatus() healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := in.health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN
+	}
+
+	return resp.GetStatus()
+}
+
+// admit identifies the instance to the caller, and delays or refuses the
+// call according to its latency profile and health.
+func (in *instance) admit(ctx context.Context, fullMethod string, setHeader func(metadata.MD) error) error {
+	if strings.HasPrefix(fullMethod, "/grpc.health.v1.") {
+		return nil
+	}
+
+	if err := setHeader(metadata.Pairs(instanceHeader, in.id)); err != nil {
+		return err
+	}
+
+	if st := in.healthStatus(); st != healthpb.HealthCheckResponse_SERVING {
+		return status.Errorf(codes.Unavailable, "instance %s is %v", in.id, st)
+	}
+
+	delay := in.latency
+	if in.jitter > 0 {
+		delay += time.Duration(rand.Int63n(2*int64(in.jitter)+1)) - in.jitter
+	}
+
+	return sleepContext(ctx, delay)
+}
+
+func (in *instance) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	setHeader := func(md metadata.MD) error {
+		return grpc.SetHeader(ctx, md)
+	}
+	if err := in.admit(ctx, info.FullMethod, setHeader); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (in *instance) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := in.admit(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// newServer builds an independent Bouncer and Chat backend for the
+// instance, following the same scenario as the main listener.
+func (in *instance) newServer(chatHeartbeat time.Duration, sc *scenario) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(proxyUnaryInterceptor, in.unaryInterceptor, sc.unaryInterceptor),
+		grpc.ChainStreamInterceptor(proxyStreamInterceptor, in.streamInterceptor, sc.streamInterceptor),
+	)
+	pb.RegisterBouncerServer(s, &server{})
+	cpb.RegisterChatServer(s, newChatServer(chatHeartbeat))
+	healthpb.RegisterHealthServer(s, in.health)
+
+	return s
+}
+
+func (in *instance) serve(chatHeartbeat time.Duration, sc *scenario) {
+	lis, err := listen(in.addr)
+	if err != nil {
+		log.Fatalf("instance %s: failed to listen: %v", in.id, err)
+	}
+
+	s := in.newServer(chatHeartbeat, sc)
+
+	log.Printf("instance %s listening at %v", in.id, lis.Addr())
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("instance %s: failed to serve: %v", in.id, err)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cpb "target/chatservice"
+	pb "target/targetservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startInstances serves every definition in-process and returns the
+// instances with a connection to each of them.
+func startInstances(t *testing.T, defs ...string) ([]*instance, map[string]*grpc.ClientConn) {
+	t.Helper()
+
+	var instances instanceFlags
+	for _, def := range defs {
+		if err := instances.Set(def); err != nil {
+			t.Fatalf("instance %q: %v", def, err)
+		}
+	}
+
+	sc, err := newScenario("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := map[string]*grpc.ClientConn{}
+	for _, in := range instances {
+		conns[in.id] = dialServer(t, in.newServer(0, sc))
+	}
+
+	return instances, conns
+}
+
+// sayHello returns the instance id the reply came with.
+func sayHello(t *testing.T, conn *grpc.ClientConn) (string, error) {
+	t.Helper()
+
+	var header metadata.MD
+	_, err := pb.NewBouncerClient(conn).SayHello(context.Background(),
+		&pb.HelloRequest{Greeting: "john_doe"}, grpc.Header(&header))
+
+	if ids := header.Get(instanceHeader); len(ids) == 1 {
+		return ids[0], err
+	}
+
+	return "", err
+}
+
+func TestParseInstance(t *testing.T) {
+	in, err := parseInstance("b@:15022?latency=50ms&jitter=5ms&health=not_serving")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.id != "b" || in.addr != ":15022" || in.latency != 50*time.Millisecond || in.jitter != 5*time.Millisecond {
+		t.Errorf("got %+v", in)
+	}
+	if in.healthStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("got health %v, want NOT_SERVING", in.healthStatus())
+	}
+
+	for _, def := range []string{"@:1", ":1", "a@:1?latency=soon", "a@:1?health=sick", "a@:1?%zz"} {
+		if _, err := parseInstance(def); err == nil {
+			t.Errorf("%q: expected an error", def)
+		}
+	}
+
+	var instances instanceFlags
+	if err := instances.Set("a@:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := instances.Set("a@:2"); err == nil {
+		t.Errorf("expected an error for an instance defined twice")
+	}
+}
+
+func TestInstanceIdentity(t *testing.T) {
+	_, conns := startInstances(t, "a@bufnet", "b@bufnet")
+
+	for _, id := range []string{"a", "b"} {
+		got, err := sayHello(t, conns[id])
+		if err != nil {
+			t.Fatalf("SayHello: %v", err)
+		}
+		if got != id {
+			t.Errorf("got reply from instance %q, want %q", got, id)
+		}
+
+		// streams are identified too
+		stream, err := cpb.NewChatClient(conns[id]).Talk(context.Background())
+		if err != nil {
+			t.Fatalf("Talk: %v", err)
+		}
+		if err := stream.Send(&cpb.ChatMessage{Room: "lobby", Sender: "alice"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		header, err := stream.Header()
+		if err != nil {
+			t.Fatalf("Header: %v", err)
+		}
+		if ids := header.Get(instanceHeader); len(ids) != 1 || ids[0] != id {
+			t.Errorf("got stream from instance %v, want %q", ids, id)
+		}
+		stream.CloseSend()
+	}
+}
+
+func TestInstanceHealth(t *testing.T) {
+	instances, conns := startInstances(t, "a@bufnet", "b@bufnet")
+	control := httptest.NewServer(newControlMux(instances, nil))
+	defer control.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, control.URL+"/instances/b/health?status=NOT_SERVING", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", res.StatusCode)
+	}
+
+	// only b is down, and still says who it is
+	if id, err := sayHello(t, conns["a"]); err != nil || id != "a" {
+		t.Errorf("a: got %q, %v", id, err)
+	}
+	id, err := sayHello(t, conns["b"])
+	if status.Code(err) != codes.Unavailable || id != "b" {
+		t.Errorf("b: got %q, %v, want code %v", id, err, codes.Unavailable)
+	}
+
+	for id, want := range map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"a": healthpb.HealthCheckResponse_SERVING,
+		"b": healthpb.HealthCheckResponse_NOT_SERVING,
+	} {
+		resp, err := healthpb.NewHealthClient(conns[id]).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		if resp.GetStatus() != want {
+			t.Errorf("%s: got health %v, want %v", id, resp.GetStatus(), want)
+		}
+	}
+
+	for path, code := range map[string]int{
+		"/instances/c/health?status=SERVING": http.StatusNotFound,
+		"/instances/a/health?status=sick":    http.StatusBadRequest,
+		"/instances/a/latency":               http.StatusNotFound,
+	} {
+		req, _ := http.NewRequest(http.MethodPut, control.URL+path, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != code {
+			t.Errorf("%s: got status %d, want %d", path, res.StatusCode, code)
+		}
+	}
+}
+
+func TestInstanceLatency(t *testing.T) {
+	const latency = 100 * time.Millisecond
+	instances, conns := startInstances(t, "slow@bufnet?latency=100ms", "fast@bufnet")
+
+	for id, slow := range map[string]bool{"slow": true, "fast": false} {
+		start := time.Now()
+		if _, err := sayHello(t, conns[id]); err != nil {
+			t.Fatalf("SayHello: %v", err)
+		}
+		if took := time.Since(start); (took >= latency) != slow {
+			t.Errorf("%s: took %v", id, took)
+		}
+	}
+
+	control := httptest.NewServer(newControlMux(instances, nil))
+	defer control.Close()
+
+	res, err := http.Get(control.URL + "/instances")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var infos []map[string]string
+	if err := json.NewDecoder(res.Body).Decode(&infos); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]string{
+		{"id": "slow", "addr": "bufnet", "health": "SERVING", "latency": "100ms", "jitter": "0s"},
+		{"id": "fast", "addr": "bufnet", "health": "SERVING", "latency": "0s", "jitter": "0s"},
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("got %v, want %v", infos, want)
+	}
+	for i := range want {
+		for k, v := range want[i] {
+			if infos[i][k] != v {
+				t.Errorf("instance %d: got %s %q, want %q", i, k, infos[i][k], v)
+			}
+		}
+	}
+}
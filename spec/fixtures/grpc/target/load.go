@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	cpb "target/chatservice"
+	pb "target/targetservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// loadCall makes one call on behalf of a load worker.
+type loadCall func(ctx context.Context) error
+
+// loadSetup prepares the calls of a worker, opening its streams.
+type loadSetup func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error)
+
+// loadCalls build the calls a worker can drive, by name.
+var loadCalls = map[string]loadSetup{
+	"SayHello": func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		client := pb.NewBouncerClient(conn)
+		return func(ctx context.Context) error {
+			_, err := client.SayHello(ctx, &pb.HelloRequest{Greeting: "load"})
+			return err
+		}, nil
+	},
+
+	"BounceIt": func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		client := pb.NewBouncerClient(conn)
+		return func(ctx context.Context) error {
+			now := timestamppb.Now()
+			_, err := client.BounceIt(ctx, &pb.BallIn{Message: "load", When: now, Now: now})
+			return err
+		}, nil
+	},
+
+	"GrowTail": func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		client := pb.NewBouncerClient(conn)
+		return func(ctx context.Context) error {
+			_, err := client.GrowTail(ctx, &pb.Body{Name: "load", Tail: &pb.Limb{}})
+			return err
+		}, nil
+	},
+
+	"Echo": func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		client := pb.NewBouncerClient(conn)
+		return func(ctx context.Context) error {
+			_, err := client.Echo(ctx, &pb.EchoMsg{Array: []string{"a", "b", "c"}, Nullable: "load"})
+			return err
+		}, nil
+	},
+
+	// one message echoed back on a stream kept by the worker
+	"EchoStream": func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		stream, err := pb.NewBouncerClient(conn).EchoStream(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		seq := 0
+		return func(ctx context.Context) error {
+			seq++
+			text := strconv.Itoa(seq)
+			if err := stream.Send(&pb.EchoMsg{Nullable: text}); err != nil {
+				return err
+			}
+
+			out, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			if out.GetNullable() != text {
+				return fmt.Errorf("echoed %q, sent %q", out.GetNullable(), text)
+			}
+			return nil
+		}, nil
+	},
+
+	// one message between two streams of a private chat room; the latency
+	// is the time until the other stream receives it
+	"Talk": func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		client := cpb.NewChatClient(conn)
+		room := fmt.Sprintf("load-%d", worker)
+
+		sender, err := client.Talk(ctx)
+		if err != nil {
+			return nil, err
+		}
+		receiver, err := client.Talk(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// each stream sees its own JOINED event once it is in the room
+		for _, stream := range []cpb.Chat_TalkClient{sender, receiver} {
+			if err := stream.Send(&cpb.ChatMessage{Room: room, Sender: room}); err != nil {
+				return nil, err
+			}
+			if _, err := stream.Recv(); err != nil {
+				return nil, err
+			}
+		}
+
+		seq := 0
+		return func(ctx context.Context) error {
+			seq++
+			text := strconv.Itoa(seq)
+			if err := sender.Send(&cpb.ChatMessage{Text: text}); err != nil {
+				return err
+			}
+
+			for {
+				ev, err := receiver.Recv()
+				if err != nil {
+					return err
+				}
+				if ev.GetKind() == cpb.ChatEvent_MESSAGE && ev.GetText() == text {
+					return nil
+				}
+			}
+		}, nil
+	},
+}
+
+// loadReport is written as JSON on stdout once the load run is over.
+type loadReport struct {
+	Target       string         `json:"target"`
+	Call         string         `json:"call"`
+	Concurrency  int            `json:"concurrency"`
+	Rate         float64        `json:"rate"`
+	Duration     float64        `json:"duration_s"`
+	Requests     int            `json:"requests"`
+	Errors       int            `json:"errors"`
+	ErrorsByCode map[string]int `json:"errors_by_code"`
+	// workers that couldn't start, e.g. open their streams, and made no
+	// requests
+	SetupErrors       int                `json:"setup_errors"`
+	SetupErrorsByCode map[string]int     `json:"setup_errors_by_code"`
+	Throughput        float64            `json:"throughput_rps"`
+	LatencyMillis     map[string]float64 `json:"latency_ms"`
+}
+
+// loadInterval returns the time between two calls at rate calls per
+// second, 0 for no pacing.
+func loadInterval(rate float64) (time.Duration, error) {
+	if rate == 0 {
+		return 0, nil
+	}
+	// tickers take 1ns at least
+	if !(rate > 0 && rate <= float64(time.Second)) {
+		return 0, fmt.Errorf("rate must be between 0 and %d calls per second", time.Second)
+	}
+
+	return time.Duration(float64(time.Second) / rate), nil
+}
+
+// loadResults is what the workers of a load run saw.
+type loadResults struct {
+	latencies         []time.Duration
+	errorsByCode      map[string]int
+	setupErrorsByCode map[string]int
+}
+
+// driveLoad runs concurrency workers making calls with newCall, one for
+// each token, until tokens is closed or ctx is done.
+func driveLoad(ctx context.Context, conn *grpc.ClientConn, newCall loadSetup, concurrency int, tokens <-chan struct{}, timeout time.Duration) *loadResults {
+	var mu sync.Mutex
+	res := &loadResults{
+		errorsByCode:      map[string]int{},
+		setupErrorsByCode: map[string]int{},
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			do, err := newCall(ctx, conn, w)
+			if err != nil {
+				mu.Lock()
+				res.setupErrorsByCode[status.Code(err).String()]++
+				mu.Unlock()
+				return
+			}
+
+			for range tokens {
+				callCtx, callCancel := context.WithTimeout(ctx, timeout)
+				t := time.Now()
+				err := do(callCtx)
+				elapsed := time.Since(t)
+				callCancel()
+
+				if ctx.Err() != nil {
+					// cut short by the end of the run
+					return
+				}
+
+				mu.Lock()
+				if err != nil {
+					res.errorsByCode[status.Code(err).String()]++
+				} else {
+					res.latencies = append(res.latencies, elapsed)
+				}
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return res
+}
+
+// runLoad implements `target load`, which drives Bouncer or Chat calls
+// against addr, either the target itself or Kong in front of it.
+func runLoad(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	addr := fs.String("addr", "localhost"+port, "address to send the calls to, e.g. Kong's proxy")
+	authority := fs.String("authority", "", "override the :authority of the calls")
+	useTLS := fs.Bool("tls", false, "use TLS, without verifying the certificate")
+	call := fs.String("call", "SayHello", "call to make: SayHello, BounceIt, GrowTail, Echo, EchoStream or Talk")
+	concurrency := fs.Int("concurrency", 10, "number of concurrent workers")
+	rate := fs.Float64("rate", 0, "total calls per second, 0 for as fast as possible")
+	duration := fs.Duration("duration", 10*time.Second, "how long to run")
+	requests := fs.Int("requests", 0, "stop after this many calls, 0 for no limit")
+	timeout := fs.Duration("timeout", 5*time.Second, "deadline of each call")
+	fs.Parse(args)
+
+	newCall, ok := loadCalls[*call]
+	if !ok {
+		return fmt.Errorf("unknown call %q", *call)
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	interval, err := loadInterval(*rate)
+	if err != nil {
+		return err
+	}
+
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if *useTLS {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}))}
+	}
+	if *authority != "" {
+		opts = append(opts, grpc.WithAuthority(*authority))
+	}
+
+	conn, err := grpc.Dial(*addr, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	// a shared budget of calls, paced by the rate if there is one
+	tokens := make(chan struct{})
+	go func() {
+		defer close(tokens)
+
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for n := 0; *requests == 0 || n < *requests; n++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	res := driveLoad(ctx, conn, newCall, *concurrency, tokens, *timeout)
+	elapsed := time.Since(start)
+
+	count := func(byCode map[string]int) int {
+		n := 0
+		for _, c := range byCode {
+			n += c
+		}
+		return n
+	}
+	errs := count(res.errorsByCode)
+
+	report := loadReport{
+		Target:            *addr,
+		Call:              *call,
+		Concurrency:       *concurrency,
+		Rate:              *rate,
+		Duration:          elapsed.Seconds(),
+		Requests:          len(res.latencies) + errs,
+		Errors:            errs,
+		ErrorsByCode:      res.errorsByCode,
+		SetupErrors:       count(res.setupErrorsByCode),
+		SetupErrorsByCode: res.setupErrorsByCode,
+		Throughput:        float64(len(res.latencies)) / elapsed.Seconds(),
+		LatencyMillis:     latencySummary(res.latencies),
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(report)
+}
+
+// latencySummary gives the min, mean, max and the usual percentiles, in
+// milliseconds.
+func latencySummary(latencies []time.Duration) map[string]float64 {
+	summary := map[string]float64{}
+	if len(latencies) == 0 {
+		return summary
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	millis := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+
+	summary["min"] = millis(latencies[0])
+	summary["mean"] = millis(total / time.Duration(len(latencies)))
+	summary["max"] = millis(latencies[len(latencies)-1])
+
+	for _, p := range []float64{50, 90, 95, 99, 99.9} {
+		// nearest rank
+		rank := int(math.Ceil(p / 100 * float64(len(latencies))))
+		summary["p"+strconv.FormatFloat(p, 'f', -1, 64)] = millis(latencies[rank-1])
+	}
+
+	return summary
+}
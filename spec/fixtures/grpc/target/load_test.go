@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	cpb "target/chatservice"
+	pb "target/targetservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLatencySummary(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	want := map[string]float64{
+		"min":   1,
+		"mean":  50.5,
+		"max":   100,
+		"p50":   50,
+		"p90":   90,
+		"p95":   95,
+		"p99":   99,
+		"p99.9": 100,
+	}
+	if got := latencySummary(latencies); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := latencySummary(nil); len(got) != 0 {
+		t.Errorf("got %v for no latencies, want an empty summary", got)
+	}
+}
+
+func TestLoadInterval(t *testing.T) {
+	for rate, want := range map[float64]time.Duration{
+		0:   0,
+		0.5: 2 * time.Second,
+		100: 10 * time.Millisecond,
+		1e9: time.Nanosecond,
+	} {
+		got, err := loadInterval(rate)
+		if err != nil {
+			t.Errorf("%v: %v", rate, err)
+		}
+		if got != want {
+			t.Errorf("%v: got %v, want %v", rate, got, want)
+		}
+	}
+
+	for _, rate := range []float64{-1, 1e9 + 1, math.Inf(1), math.NaN()} {
+		if _, err := loadInterval(rate); err == nil {
+			t.Errorf("%v: expected an error", rate)
+		}
+	}
+}
+
+// tokens returns a closed channel holding n tokens.
+func tokens(n int) <-chan struct{} {
+	c := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		c <- struct{}{}
+	}
+	close(c)
+	return c
+}
+
+func TestLoadCalls(t *testing.T) {
+	s := grpc.NewServer()
+	pb.RegisterBouncerServer(s, &server{})
+	cpb.RegisterChatServer(s, newChatServer(0))
+	conn := dialServer(t, s)
+
+	for name, newCall := range loadCalls {
+		res := driveLoad(context.Background(), conn, newCall, 2, tokens(10), 5*time.Second)
+		if len(res.latencies) != 10 || len(res.errorsByCode) != 0 || len(res.setupErrorsByCode) != 0 {
+			t.Errorf("%s: got %d calls, errors %v, setup errors %v",
+				name, len(res.latencies), res.errorsByCode, res.setupErrorsByCode)
+		}
+	}
+}
+
+func TestLoadSetupErrors(t *testing.T) {
+	conn := dialTarget(t)
+
+	// a worker out of three can't start, the others make every call
+	newCall := func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		if worker == 0 {
+			return nil, status.Error(codes.Unavailable, "no stream")
+		}
+		return loadCalls["EchoStream"](ctx, conn, worker)
+	}
+
+	res := driveLoad(context.Background(), conn, newCall, 3, tokens(10), 5*time.Second)
+	if len(res.latencies) != 10 || len(res.errorsByCode) != 0 {
+		t.Errorf("got %d calls, errors %v", len(res.latencies), res.errorsByCode)
+	}
+	if want := map[string]int{"Unavailable": 1}; !reflect.DeepEqual(res.setupErrorsByCode, want) {
+		t.Errorf("got setup errors %v, want %v", res.setupErrorsByCode, want)
+	}
+
+	// and call errors are counted as such
+	failing := func(ctx context.Context, conn *grpc.ClientConn, worker int) (loadCall, error) {
+		return func(ctx context.Context) error { return errors.New("nope") }, nil
+	}
+	res = driveLoad(context.Background(), conn, failing, 2, tokens(4), 5*time.Second)
+	if want := map[string]int{"Unknown": 4}; !reflect.DeepEqual(res.errorsByCode, want) || len(res.setupErrorsByCode) != 0 {
+		t.Errorf("got errors %v, setup errors %v", res.errorsByCode, res.setupErrorsByCode)
+	}
+}
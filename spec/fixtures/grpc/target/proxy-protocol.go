@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// response headers reporting where a call came from when the
+	// listener expects the PROXY protocol
+	peerHeader        = "x-peer-address"
+	proxyClientHeader = "x-proxy-client-address"
+
+	// longest v1 header, CRLF included
+	proxyV1MaxLength = 107
+
+	// time given to new connections to send their PROXY header
+	proxyHeaderTimeout = 5 * time.Second
+)
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyAddr is the remote address of a connection that began with a PROXY
+// protocol header: the client address it was told about, and the real
+// transport peer.  client is nil for v1 UNKNOWN and v2 LOCAL headers.
+type proxyAddr struct {
+	transport net.Addr
+	client    net.Addr
+}
+
+// Network isn't "tcp", which channelz would take for a *net.TCPAddr.
+func (a *proxyAddr) Network() string {
+	return a.transport.Network() + "+proxy"
+}
+
+func (a *proxyAddr) String() string {
+	if a.client == nil {
+		return a.transport.String()
+	}
+
+	return a.client.String()
+}
+
+func (a *proxyAddr) metadata() metadata.MD {
+	client := ""
+	if a.client != nil {
+		client = a.client.String()
+	}
+
+	return metadata.Pairs(peerHeader, a.transport.String(), proxyClientHeader, client)
+}
+
+// listen opens a TCP listener, expecting PROXY headers with -proxy-protocol.
+func listen(addr string) (net.Listener, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil || !*proxyProto {
+		return lis, err
+	}
+
+	return newProxyListener(lis, proxyHeaderTimeout), nil
+}
+
+// dialProxied connects the target to its own listeners, announcing itself
+// with a PROXY v1 UNKNOWN header when they expect one.
+func dialProxied(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil || !*proxyProto {
+		return conn, err
+	}
+
+	if _, err := io.WriteString(conn, "PROXY UNKNOWN\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// proxyListener expects every accepted connection to start with a PROXY
+// protocol v1 or v2 header.  Headers are read as connections come, each
+// from its own goroutine and within a deadline, so that clients sending
+// nothing hold up neither Accept nor the users of the connection;
+// connections without a valid header are dropped.
+type proxyListener struct {
+	net.Listener
+
+	timeout time.Duration
+	conns   chan net.Conn
+
+	// closed when the listener fails, with err set
+	failed chan struct{}
+	err    error
+}
+
+func newProxyListener(lis net.Listener, timeout time.Duration) *proxyListener {
+	l := &proxyListener{
+		Listener: lis,
+		timeout:  timeout,
+		conns:    make(chan net.Conn),
+		failed:   make(chan struct{}),
+	}
+	go l.serve()
+
+	return l
+}
+
+func (l *proxyListener) serve() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.err = err
+			close(l.failed)
+			return
+		}
+
+		go l.handshake(conn)
+	}
+}
+
+func (l *proxyListener) handshake(conn net.Conn) {
+	pc, err := newProxyConn(conn, l.timeout)
+	if err != nil {
+		log.Printf("dropping connection from %v: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	select {
+	case l.conns <- pc:
+	case <-l.failed:
+		conn.Close()
+	}
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.failed:
+		return nil, l.err
+	}
+}
+
+// proxyConn is a connection past its PROXY header.
+type proxyConn struct {
+	net.Conn
+
+	r    *bufio.Reader
+	addr *proxyAddr
+}
+
+func newProxyConn(conn net.Conn, timeout time.Duration) (*proxyConn, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	client, err := readProxyHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return &proxyConn{
+		Conn: conn,
+		r:    r,
+		addr: &proxyAddr{transport: conn.RemoteAddr(), client: client},
+	}, nil
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, fmt.Errorf("reading PROXY header: %w", err)
+	}
+
+	if bytes.Equal(sig, proxyV2Signature) {
+		return readProxyV2(r)
+	}
+	if bytes.HasPrefix(sig, []byte("PROXY ")) {
+		return readProxyV1(r)
+	}
+
+	return nil, fmt.Errorf("missing PROXY header")
+}
+
+// readProxyV1 parses "PROXY TCP4|TCP6 SRC DST SRCPORT DSTPORT\r\n" or
+// "PROXY UNKNOWN ...\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return nil, fmt.Errorf("PROXY v1 header too long")
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading PROXY v1 header: %w", err)
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY v1 header %q", line)
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("invalid PROXY v1 source in %q", line)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2 parses the binary header, skipping any TLVs.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(proxyV2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("reading PROXY v2 header: %w", err)
+	}
+
+	verCmd, family := header[12], header[13]
+	length := binary.BigEndian.Uint16(header[14:])
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("reading PROXY v2 addresses: %w", err)
+	}
+
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY version %d", verCmd>>4)
+	}
+	if verCmd&0xf == 0 {
+		// LOCAL: the proxy talking for itself, e.g. health checks
+		return nil, nil
+	}
+
+	switch family >> 4 {
+	case 1: // AF_INET
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("short PROXY v2 IPv4 addresses")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:4]),
+			Port: int(binary.BigEndian.Uint16(payload[8:])),
+		}, nil
+
+	case 2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("short PROXY v2 IPv6 addresses")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:16]),
+			Port: int(binary.BigEndian.Uint16(payload[32:])),
+		}, nil
+	}
+
+	// AF_UNSPEC and AF_UNIX carry nothing we can report
+	return nil, nil
+}
+
+// proxyMetadata reports both addresses of calls made on connections that
+// began with a PROXY header.
+func proxyMetadata(ctx context.Context) metadata.MD {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	addr, ok := p.Addr.(*proxyAddr)
+	if !ok {
+		return nil
+	}
+
+	return addr.metadata()
+}
+
+func proxyUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md := proxyMetadata(ctx); md != nil {
+		if err := grpc.SetHeader(ctx, md); err != nil {
+			return nil, err
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+func proxyStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if md := proxyMetadata(ss.Context()); md != nil {
+		if err := ss.SetHeader(md); err != nil {
+			return err
+		}
+	}
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	v2 := func(verCmd, family byte, addrs ...byte) string {
+		return string(proxyV2Signature) + string([]byte{verCmd, family, 0, byte(len(addrs))}) + string(addrs)
+	}
+
+	cases := []struct {
+		name   string
+		header string
+		client string
+		err    bool
+	}{
+		{"v1 TCP4", "PROXY TCP4 192.0.2.1 198.51.100.1 5000 15010\r\n", "192.0.2.1:5000", false},
+		{"v1 TCP6", "PROXY TCP6 2001:db8::1 2001:db8::2 5000 15010\r\n", "[2001:db8::1]:5000", false},
+		{"v1 UNKNOWN", "PROXY UNKNOWN\r\n", "", false},
+		{"v1 bad port", "PROXY TCP4 192.0.2.1 198.51.100.1 port 15010\r\n", "", true},
+		{"v1 too long", "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", "", true},
+		{"v2 IPv4", v2(0x21, 0x11, 192, 0, 2, 1, 198, 51, 100, 1, 0x13, 0x88, 0x3a, 0xb2), "192.0.2.1:5000", false},
+		{"v2 IPv4 with TLV", v2(0x21, 0x11, 192, 0, 2, 1, 198, 51, 100, 1, 0x13, 0x88, 0x3a, 0xb2, 0x04, 0, 1, 'x'), "192.0.2.1:5000", false},
+		{"v2 LOCAL", v2(0x20, 0x00), "", false},
+		{"v2 bad version", v2(0x11, 0x11), "", true},
+		{"no header", "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(c.header + "rest"))
+			client, err := readProxyHeader(r)
+			if c.err {
+				if err == nil {
+					t.Fatalf("got client %v, want an error", client)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readProxyHeader: %v", err)
+			}
+
+			got := ""
+			if client != nil {
+				got = client.String()
+			}
+			if got != c.client {
+				t.Errorf("got client %q, want %q", got, c.client)
+			}
+
+			// the header must be consumed, and only the header
+			if rest, _ := ioutil.ReadAll(r); string(rest) != "rest" {
+				t.Errorf("got %q after the header, want %q", rest, "rest")
+			}
+		})
+	}
+}
+
+func TestProxyListener(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := newProxyListener(tcp, 200*time.Millisecond)
+	defer lis.Close()
+
+	dial := func(header string) net.Conn {
+		conn, err := net.Dial("tcp", tcp.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		if _, err := io.WriteString(conn, header); err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	// neither a client saying nothing nor a bad header hold up the next
+	silent := dial("")
+	dial("GET / HTTP/1.1\r\n\r\n")
+	dial("PROXY TCP4 192.0.2.1 198.51.100.1 5000 15010\r\nrest")
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+
+	select {
+	case conn := <-accepted:
+		if got := conn.RemoteAddr().String(); got != "192.0.2.1:5000" {
+			t.Errorf("got client %q", got)
+		}
+		if rest, _ := ioutil.ReadAll(io.LimitReader(conn, 4)); string(rest) != "rest" {
+			t.Errorf("got %q after the header, want %q", rest, "rest")
+		}
+		conn.Close()
+	case <-time.After(time.Second):
+		t.Fatalf("Accept blocked by the other connections")
+	}
+
+	// the silent client is dropped once its time is up
+	silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := silent.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("got %v from the silent connection, want EOF", err)
+	}
+
+	// and Accept reports the listener closing
+	lis.Close()
+	if _, err := lis.Accept(); err == nil {
+		t.Errorf("Accept succeeded on a closed listener")
+	}
+}
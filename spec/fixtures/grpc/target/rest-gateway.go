@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	pb "target/targetservice"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// newRESTGateway builds a reference REST transcoder for every
+// google.api.http binding declared in targetservice.proto.
+//
+// The bindings are read from the compiled descriptor instead of being
+// generated, so the reference always follows the same .proto file that
+// Kong's grpc-gateway plugin is configured with.  Requests are forwarded
+// to the gRPC listener through conn.
+func newRESTGateway(conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		// match the JSON produced by Kong's grpc-gateway plugin:
+		// field names as written in the .proto and zero values included
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		// Kong passes response metadata through under its own name
+		// instead of the Grpc-Metadata- prefix
+		runtime.WithOutgoingHeaderMatcher(func(key string) (string, bool) {
+			if key == "content-type" || strings.HasPrefix(key, "grpc-") {
+				return "", false
+			}
+			return key, true
+		}),
+	)
+
+	services := pb.File_targetservice_proto.Services()
+	for i := 0; i < services.Len(); i++ {
+		methods := services.Get(i).Methods()
+		for j := 0; j < methods.Len(); j++ {
+			md := methods.Get(j)
+			if md.IsStreamingClient() || md.IsStreamingServer() {
+				continue
+			}
+
+			rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+			if !ok || rule == nil {
+				continue
+			}
+
+			for _, binding := range flattenHttpRule(rule) {
+				verb, path := httpRulePattern(binding)
+				if verb == "" {
+					continue
+				}
+
+				h, err := restHandler(mux, conn, md, binding.GetBody())
+				if err != nil {
+					return nil, err
+				}
+
+				// the path template grammar has no trailing slash, which
+				// targetservice.proto uses; see trimTrailingSlash
+				path = strings.TrimSuffix(path, "/")
+
+				if err := mux.HandlePath(verb, path, h); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", verb, path, err)
+				}
+			}
+		}
+	}
+
+	return trimTrailingSlash(mux), nil
+}
+
+// trimTrailingSlash lets "/v1/messages/" reach the "/v1/messages" pattern.
+func trimTrailingSlash(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > 1 {
+			r.URL.Path = strings.TrimSuffix(r.URL.Path, "/")
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// flattenHttpRule returns the rule and all its additional bindings.
+// Nested additional_bindings are not allowed by google/api/http.proto but
+// targetservice.proto uses them, so they are walked as well.
+func flattenHttpRule(rule *annotations.HttpRule) []*annotations.HttpRule {
+	rules := []*annotations.HttpRule{rule}
+	for _, additional := range rule.GetAdditionalBindings() {
+		rules = append(rules, flattenHttpRule(additional)...)
+	}
+
+	return rules
+}
+
+func httpRulePattern(rule *annotations.HttpRule) (string, string) {
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, pattern.Get
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, pattern.Put
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, pattern.Post
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, pattern.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, pattern.Patch
+	case *annotations.HttpRule_Custom:
+		return pattern.Custom.GetKind(), pattern.Custom.GetPath()
+	}
+
+	return "", ""
+}
+
+func restHandler(mux *runtime.ServeMux, conn *grpc.ClientConn, md protoreflect.MethodDescriptor, body string) (runtime.HandlerFunc, error) {
+	fullMethod := fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+
+	inType, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+	if err != nil {
+		return nil, err
+	}
+	outType, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		inbound, outbound := runtime.MarshalerForRequest(mux, r)
+
+		ctx, err := runtime.AnnotateContext(ctx, mux, r, fullMethod)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		in := inType.New().Interface()
+		out := outType.New().Interface()
+
+		if err := decodeRESTRequest(inbound, r, pathParams, body, in); err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		var header, trailer metadata.MD
+		err = conn.Invoke(ctx, fullMethod, in, out, grpc.Header(&header), grpc.Trailer(&trailer))
+		ctx = runtime.NewServerMetadataContext(ctx, runtime.ServerMetadata{
+			HeaderMD:  header,
+			TrailerMD: trailer,
+		})
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		runtime.ForwardResponseMessage(ctx, mux, outbound, w, r, out, mux.GetForwardResponseOptions()...)
+	}, nil
+}
+
+// decodeRESTRequest fills in from the request body, the path parameters
+// and, for fields not bound elsewhere, the query string.
+func decodeRESTRequest(inbound runtime.Marshaler, r *http.Request, pathParams map[string]string, body string, in proto.Message) error {
+	var bound [][]string
+
+	if body != "" {
+		target := in
+		if body != "*" {
+			fd := in.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(body))
+			if fd == nil || fd.Message() == nil {
+				return fmt.Errorf("unsupported body field %q", body)
+			}
+			target = in.ProtoReflect().Mutable(fd).Message().Interface()
+			bound = append(bound, []string{body})
+		}
+
+		if err := inbound.NewDecoder(r.Body).Decode(target); err != nil && err != io.EOF {
+			return status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+
+	for name, value := range pathParams {
+		if err := runtime.PopulateFieldFromPath(in, name, value); err != nil {
+			return status.Errorf(codes.InvalidArgument, "%s: %v", name, err)
+		}
+		bound = append(bound, strings.Split(name, "."))
+	}
+
+	if body == "*" {
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	if err := runtime.PopulateQueryParameters(in, r.Form, utilities.NewDoubleArray(bound)); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	return nil
+}
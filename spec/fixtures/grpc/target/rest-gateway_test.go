@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "target/targetservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/rest")
+
+// restGoldenCases are the transcodings the grpc-gateway specs depend on.
+var restGoldenCases = []struct {
+	name   string
+	method string
+	path   string
+	body   string
+}{
+	{"say-hello", "GET", "/v1/messages/john_doe", ""},
+	{"say-hello-legacy", "GET", "/v1/messages/legacy/john_doe?boolean_test=true", ""},
+	{"say-hello-post", "POST", "/v1/messages/?greeting=john_doe", ""},
+	{"unknown-method", "GET", "/v1/unknown/john_doe", ""},
+	{"bounce-it", "POST", "/bounce", `{"message":"hi","when":"2021-07-16T13:33:40Z","now":"2021-07-16T13:38:55Z"}`},
+	{"grow-tail", "GET", "/v1/grow/tail?name=dog&tail.count=1&tail.endings=fluffy", ""},
+	{"echo", "POST", "/v1/echo", `{"array":["a","b"],"nullable":"ahaha"}`},
+	{"echo-empty-array", "POST", "/v1/echo", `{"array":[],"nullable":"ahaha"}`},
+	{"echo-empty-message", "POST", "/v1/echo", `{"array":[],"nullable":""}`},
+}
+
+func TestRESTGolden(t *testing.T) {
+	gateway, err := newRESTGateway(dialTarget(t))
+	if err != nil {
+		t.Fatalf("failed to build REST gateway: %v", err)
+	}
+
+	for _, tc := range restGoldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			gateway.ServeHTTP(rec, req)
+
+			// protojson randomizes its whitespace
+			var body bytes.Buffer
+			if err := json.Indent(&body, rec.Body.Bytes(), "", "  "); err != nil {
+				t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+			}
+			got := fmt.Sprintf("%d %s\n%s\n", rec.Code, http.StatusText(rec.Code), body.String())
+
+			golden := filepath.Join("testdata", "rest", tc.name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("%s %s:\ngot:\n%s\nwant:\n%s", tc.method, tc.path, got, want)
+			}
+		})
+	}
+}
+
+// Response metadata keeps its name, as Kong passes it through.
+func TestRESTMetadataHeaders(t *testing.T) {
+	s := grpc.NewServer(grpc.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			grpc.SetHeader(ctx, metadata.Pairs("x-from", "target", "grpc-custom", "hidden"))
+			return handler(ctx, req)
+		}))
+	pb.RegisterBouncerServer(s, &server{})
+
+	gateway, err := newRESTGateway(dialServer(t, s))
+	if err != nil {
+		t.Fatalf("failed to build REST gateway: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	gateway.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/messages/john_doe", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	for header, want := range map[string]string{
+		"Content-Type":              "application/json",
+		"X-From":                    "target",
+		"Grpc-Metadata-X-From":      "",
+		"Grpc-Custom":               "",
+		"Grpc-Metadata-Grpc-Custom": "",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("got %s %q, want %q", header, got, want)
+		}
+	}
+}
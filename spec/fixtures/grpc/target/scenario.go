@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"sigs.k8s.io/yaml"
+)
+
+// A scenario overrides the hardcoded handlers for the calls matching its
+// rules.  It is read from a YAML or JSON file like:
+//
+//	rules:
+//	  - method: /targetservice.Bouncer/SayHello
+//	    match:
+//	      metadata: { x-scenario: slow }
+//	      request: { greeting: john }
+//	    response:
+//	      delay: 2s
+//	      headers: { x-from: scenario }
+//	      trailers: { x-done: "yes" }
+//	      message: { reply: "canned hello" }
+//	  - method: /targetservice.Bouncer/Echo
+//	    response:
+//	      status: { code: UNAVAILABLE, message: "down for maintenance" }
+//	  - method: /chatservice.Chat/Talk
+//	    response:
+//	      stream:
+//	        - message: { text: "first" }
+//	        - delay: 500ms
+//	        - message: { text: "second" }
+//	        - status: { code: ABORTED }
+//	  - method: /targetservice.Bouncer/GrowTail
+//	    response:
+//	      fault: rst_stream
+//
+// The first rule whose method and matchers fit the call wins.  A status
+// with no headers before it makes a trailers-only response.  Request
+// matchers compare a subset of the request, in its JSON form, and only
+// apply to unary methods.  Rules with a fault (see faults.go) only act on
+// the -faults listener; elsewhere those calls are handled normally.
+type scenario struct {
+	path string
+
+	mu    sync.RWMutex
+	rules []*scenarioRule
+}
+
+type scenarioRule struct {
+	Method   string           `json:"method"`
+	Match    scenarioMatch    `json:"match"`
+	Response scenarioResponse `json:"response"`
+
+	method protoreflect.MethodDescriptor
+}
+
+type scenarioMatch struct {
+	Metadata map[string]string      `json:"metadata"`
+	Request  map[string]interface{} `json:"request"`
+}
+
+type scenarioResponse struct {
+	scenarioStep
+	Stream []scenarioStep `json:"stream"`
+	Fault  string         `json:"fault"`
+}
+
+// scenarioStep is applied in field order: delay, headers, message,
+// trailers and then status.
+type scenarioStep struct {
+	Delay    scenarioDuration  `json:"delay"`
+	Headers  map[string]string `json:"headers"`
+	Message  json.RawMessage   `json:"message"`
+	Trailers map[string]string `json:"trailers"`
+	Status   *scenarioStatus   `json:"status"`
+}
+
+type scenarioStatus struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+type scenarioDuration time.Duration
+
+func (d *scenarioDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = scenarioDuration(v)
+
+	return nil
+}
+
+// newScenario loads the scenario at path; an empty path gives a scenario
+// without rules.
+func newScenario(path string) (*scenario, error) {
+	sc := &scenario{path: path}
+	if err := sc.reload(); err != nil {
+		return nil, err
+	}
+
+	return sc, nil
+}
+
+// reload replaces the rules with the current contents of the file.  The
+// old rules are kept if the file is invalid.
+func (sc *scenario) reload() error {
+	if sc.path == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(sc.path)
+	if err != nil {
+		return err
+	}
+
+	b, err = yaml.YAMLToJSON(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sc.path, err)
+	}
+
+	var file struct {
+		Rules []*scenarioRule `json:"rules"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return fmt.Errorf("%s: %w", sc.path, err)
+	}
+
+	for i, rule := range file.Rules {
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("%s: rule %d: %w", sc.path, i+1, err)
+		}
+	}
+
+	sc.mu.Lock()
+	sc.rules = file.Rules
+	sc.mu.Unlock()
+
+	log.Printf("scenario %s: loaded %d rules", sc.path, len(file.Rules))
+
+	return nil
+}
+
+func (rule *scenarioRule) compile() error {
+	name := strings.Replace(strings.TrimPrefix(rule.Method, "/"), "/", ".", 1)
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return fmt.Errorf("unknown method %q", rule.Method)
+	}
+
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return fmt.Errorf("%q is not a method", rule.Method)
+	}
+	rule.method = md
+	rule.Method = fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+
+	streaming := md.IsStreamingClient() || md.IsStreamingServer()
+	if streaming && rule.Match.Request != nil {
+		return fmt.Errorf("request matchers only apply to unary methods")
+	}
+	if !streaming && rule.Response.Stream != nil {
+		return fmt.Errorf("stream scripts only apply to streaming methods")
+	}
+	if rule.Response.Fault != "" {
+		if _, ok := faults[rule.Response.Fault]; !ok {
+			return fmt.Errorf("unknown fault %q", rule.Response.Fault)
+		}
+		if rule.Match.Request != nil {
+			return fmt.Errorf("request matchers can't select faults")
+		}
+	}
+
+	// decode the messages once, so that mistakes show when loading
+	steps := append([]scenarioStep{rule.Response.scenarioStep}, rule.Response.Stream...)
+	for _, step := range steps {
+		if _, err := rule.message(step); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// message decodes the message of the step, nil if it has none.
+func (rule *scenarioRule) message(step scenarioStep) (proto.Message, error) {
+	if step.Message == nil {
+		return nil, nil
+	}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(rule.method.Output().FullName())
+	if err != nil {
+		return nil, err
+	}
+
+	msg := mt.New().Interface()
+	if err := protojson.Unmarshal(step.Message, msg); err != nil {
+		return nil, fmt.Errorf("%s message: %w", rule.method.Output().FullName(), err)
+	}
+
+	return msg, nil
+}
+
+func (rule *scenarioRule) matches(ctx context.Context, fullMethod string, req interface{}) bool {
+	if rule.Method != fullMethod {
+		return false
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, v := range rule.Match.Metadata {
+		found := false
+		for _, got := range md.Get(k) {
+			found = found || got == v
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if rule.Match.Request == nil {
+		return true
+	}
+
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return false
+	}
+
+	b, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		return false
+	}
+
+	var got interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		return false
+	}
+
+	return containsJSON(got, rule.Match.Request)
+}
+
+// containsJSON tells whether every field set in want has the same value
+// in got.
+func containsJSON(got, want interface{}) bool {
+	wantMap, ok := want.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(got, want)
+	}
+
+	gotMap, ok := got.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	for k, v := range wantMap {
+		if !containsJSON(gotMap[k], v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (sc *scenario) find(ctx context.Context, fullMethod string, req interface{}) *scenarioRule {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	for _, rule := range sc.rules {
+		if rule.matches(ctx, fullMethod, req) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (step scenarioStep) status() error {
+	if step.Status == nil {
+		return nil
+	}
+
+	return status.Error(step.Status.Code, step.Status.Message)
+}
+
+func (sc *scenario) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	rule := sc.find(ctx, info.FullMethod, req)
+	if rule == nil || rule.Response.Fault != "" {
+		return handler(ctx, req)
+	}
+
+	step := rule.Response.scenarioStep
+	if err := sleepContext(ctx, time.Duration(step.Delay)); err != nil {
+		return nil, err
+	}
+	if step.Headers != nil {
+		if err := grpc.SetHeader(ctx, metadata.New(step.Headers)); err != nil {
+			return nil, err
+		}
+	}
+	if step.Trailers != nil {
+		if err := grpc.SetTrailer(ctx, metadata.New(step.Trailers)); err != nil {
+			return nil, err
+		}
+	}
+	if err := step.status(); err != nil {
+		return nil, err
+	}
+
+	msg, err := rule.message(step)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if msg == nil {
+		mt, err := protoregistry.GlobalTypes.FindMessageByName(rule.method.Output().FullName())
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		msg = mt.New().Interface()
+	}
+
+	return msg, nil
+}
+
+func (sc *scenario) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	rule := sc.find(ss.Context(), info.FullMethod, nil)
+	if rule == nil || rule.Response.Fault != "" {
+		return handler(srv, ss)
+	}
+
+	steps := append([]scenarioStep{rule.Response.scenarioStep}, rule.Response.Stream...)
+	for _, step := range steps {
+		if err := sleepContext(ss.Context(), time.Duration(step.Delay)); err != nil {
+			return err
+		}
+		if step.Headers != nil {
+			if err := ss.SetHeader(metadata.New(step.Headers)); err != nil {
+				return err
+			}
+		}
+
+		msg, err := rule.message(step)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if msg != nil {
+			if err := ss.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+
+		if step.Trailers != nil {
+			ss.SetTrailer(metadata.New(step.Trailers))
+		}
+		if err := step.status(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
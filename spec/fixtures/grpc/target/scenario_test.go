@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cpb "target/chatservice"
+	pb "target/targetservice"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testScenario = `
+rules:
+  - method: /targetservice.Bouncer/SayHello
+    match:
+      metadata: { x-scenario: canned }
+    response:
+      headers: { x-from: scenario }
+      trailers: { x-done: "yes" }
+      message: { reply: "canned hello" }
+  - method: /targetservice.Bouncer/SayHello
+    match:
+      request: { greeting: slow }
+    response:
+      delay: 100ms
+      message: { reply: "slow hello" }
+  - method: targetservice.Bouncer.Echo
+    response:
+      status: { code: UNAVAILABLE, message: "down for maintenance" }
+  - method: /chatservice.Chat/Talk
+    response:
+      headers: { x-from: scenario }
+      stream:
+        - message: { text: "first" }
+        - delay: 50ms
+        - message: { text: "second" }
+        - trailers: { x-done: "yes" }
+          status: { code: ABORTED, message: "script over" }
+`
+
+// dialScenario writes the scenario to a file, serves the test services
+// following it and returns the scenario with a connection to them.
+func dialScenario(t *testing.T, src string) (*scenario, *grpc.ClientConn) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err := newScenario(path)
+	if err != nil {
+		t.Fatalf("failed to load scenario: %v", err)
+	}
+
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(sc.unaryInterceptor),
+		grpc.StreamInterceptor(sc.streamInterceptor),
+	)
+	pb.RegisterBouncerServer(s, &server{})
+	cpb.RegisterChatServer(s, newChatServer(0))
+
+	return sc, dialServer(t, s)
+}
+
+func TestScenarioMatching(t *testing.T) {
+	_, conn := dialScenario(t, testScenario)
+	client := pb.NewBouncerClient(conn)
+
+	for _, tc := range []struct {
+		greeting string
+		md       metadata.MD
+		reply    string
+	}{
+		{"john", metadata.Pairs("x-scenario", "canned"), "canned hello"},
+		// the first rule matching wins
+		{"slow", metadata.Pairs("x-scenario", "canned"), "canned hello"},
+		{"slow", metadata.Pairs("x-scenario", "other"), "slow hello"},
+		// everything else goes to the hardcoded handler
+		{"john", metadata.Pairs("x-scenario", "other"), "hello john"},
+		{"john", nil, "hello john"},
+	} {
+		ctx := metadata.NewOutgoingContext(context.Background(), tc.md)
+		resp, err := client.SayHello(ctx, &pb.HelloRequest{Greeting: tc.greeting})
+		if err != nil {
+			t.Fatalf("SayHello: %v", err)
+		}
+		if resp.GetReply() != tc.reply {
+			t.Errorf("%s %v: got reply %q, want %q", tc.greeting, tc.md, resp.GetReply(), tc.reply)
+		}
+	}
+}
+
+func TestScenarioUnaryResponses(t *testing.T) {
+	_, conn := dialScenario(t, testScenario)
+	client := pb.NewBouncerClient(conn)
+
+	// headers and trailers
+	var header, trailer metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-scenario", "canned")
+	if _, err := client.SayHello(ctx, &pb.HelloRequest{}, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if got := header.Get("x-from"); len(got) != 1 || got[0] != "scenario" {
+		t.Errorf("got x-from header %v", got)
+	}
+	if got := trailer.Get("x-done"); len(got) != 1 || got[0] != "yes" {
+		t.Errorf("got x-done trailer %v", got)
+	}
+
+	// delay
+	start := time.Now()
+	if _, err := client.SayHello(context.Background(), &pb.HelloRequest{Greeting: "slow"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if took := time.Since(start); took < 100*time.Millisecond {
+		t.Errorf("answered after %v, want 100ms at least", took)
+	}
+
+	// which the caller can give up on
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.SayHello(ctx, &pb.HelloRequest{Greeting: "slow"}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got %v, want code %v", err, codes.DeadlineExceeded)
+	}
+
+	// status
+	_, err := client.Echo(context.Background(), &pb.EchoMsg{})
+	if st := status.Convert(err); st.Code() != codes.Unavailable || st.Message() != "down for maintenance" {
+		t.Errorf("got %v, want code %v", err, codes.Unavailable)
+	}
+}
+
+func TestScenarioStreamScript(t *testing.T) {
+	_, conn := dialScenario(t, testScenario)
+
+	stream, err := cpb.NewChatClient(conn).Talk(context.Background())
+	if err != nil {
+		t.Fatalf("Talk: %v", err)
+	}
+
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatalf("Header: %v", err)
+	}
+	if got := header.Get("x-from"); len(got) != 1 || got[0] != "scenario" {
+		t.Errorf("got x-from header %v", got)
+	}
+
+	var start time.Time
+	for _, want := range []string{"first", "second"} {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if ev.GetText() != want {
+			t.Errorf("got %q, want %q", ev.GetText(), want)
+		}
+		if start.IsZero() {
+			start = time.Now()
+		}
+	}
+	if took := time.Since(start); took < 50*time.Millisecond {
+		t.Errorf("second message after %v, want 50ms at least", took)
+	}
+
+	_, err = stream.Recv()
+	if st := status.Convert(err); st.Code() != codes.Aborted || st.Message() != "script over" {
+		t.Errorf("got %v, want code %v", err, codes.Aborted)
+	}
+	if got := stream.Trailer().Get("x-done"); len(got) != 1 || got[0] != "yes" {
+		t.Errorf("got x-done trailer %v", got)
+	}
+}
+
+func TestScenarioReload(t *testing.T) {
+	sc, conn := dialScenario(t, testScenario)
+	client := pb.NewBouncerClient(conn)
+	control := httptest.NewServer(newControlMux(nil, sc))
+	defer control.Close()
+
+	reload := func() (int, string) {
+		res, err := http.Post(control.URL+"/scenario/reload", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		body, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	if err := ioutil.WriteFile(sc.path, []byte(`
+rules:
+  - method: /targetservice.Bouncer/SayHello
+    response:
+      message: { reply: "reloaded" }
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := reload(); code != http.StatusOK || strings.TrimSpace(body) != `{"rules":1}` {
+		t.Fatalf("got %d %s", code, body)
+	}
+
+	resp, err := client.SayHello(context.Background(), &pb.HelloRequest{Greeting: "john"})
+	if err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if resp.GetReply() != "reloaded" {
+		t.Errorf("got reply %q, want %q", resp.GetReply(), "reloaded")
+	}
+	if _, err := client.Echo(context.Background(), &pb.EchoMsg{}); err != nil {
+		t.Errorf("Echo: %v", err)
+	}
+
+	// invalid files leave the rules alone
+	if err := ioutil.WriteFile(sc.path, []byte(`rules: [{method: /nope/Nope}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := reload(); code != http.StatusUnprocessableEntity || !strings.Contains(body, `unknown method "/nope/Nope"`) {
+		t.Errorf("got %d %s", code, body)
+	}
+
+	resp, err = client.SayHello(context.Background(), &pb.HelloRequest{Greeting: "john"})
+	if err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if resp.GetReply() != "reloaded" {
+		t.Errorf("got reply %q, want %q", resp.GetReply(), "reloaded")
+	}
+}
+
+func TestScenarioInvalid(t *testing.T) {
+	for src, want := range map[string]string{
+		`rules: [{method: /targetservice.Bouncer/Nope}]`:                                 "unknown method",
+		`rules: [{method: /chatservice.Chat/Talk, match: {request: {room: a}}}]`:         "request matchers only apply to unary methods",
+		`rules: [{method: /targetservice.Bouncer/Echo, response: {stream: [{}]}}]`:       "stream scripts only apply to streaming methods",
+		`rules: [{method: /targetservice.Bouncer/Echo, response: {fault: nope}}]`:        "unknown fault",
+		`rules: [{method: /targetservice.Bouncer/Echo, response: {message: {nope: 1}}}]`: "targetservice.EchoMsg message",
+		`rules: [{method: /targetservice.Bouncer/Echo, response: {delay: soon}}]`:        "soon",
+	} {
+		path := filepath.Join(t.TempDir(), "scenario.yaml")
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := newScenario(path)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", src, err, want)
+		}
+	}
+}
@@ -28,6 +28,8 @@ type BouncerClient interface {
 	BounceIt(ctx context.Context, in *BallIn, opts ...grpc.CallOption) (*BallOut, error)
 	GrowTail(ctx context.Context, in *Body, opts ...grpc.CallOption) (*Body, error)
 	Echo(ctx context.Context, in *EchoMsg, opts ...grpc.CallOption) (*EchoMsg, error)
+	// echoes every message of the stream back, in order
+	EchoStream(ctx context.Context, opts ...grpc.CallOption) (Bouncer_EchoStreamClient, error)
 }
 
 type bouncerClient struct {
@@ -83,6 +85,37 @@ func (c *bouncerClient) Echo(ctx context.Context, in *EchoMsg, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *bouncerClient) EchoStream(ctx context.Context, opts ...grpc.CallOption) (Bouncer_EchoStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Bouncer_ServiceDesc.Streams[0], "/targetservice.Bouncer/EchoStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bouncerEchoStreamClient{stream}
+	return x, nil
+}
+
+type Bouncer_EchoStreamClient interface {
+	Send(*EchoMsg) error
+	Recv() (*EchoMsg, error)
+	grpc.ClientStream
+}
+
+type bouncerEchoStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *bouncerEchoStreamClient) Send(m *EchoMsg) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *bouncerEchoStreamClient) Recv() (*EchoMsg, error) {
+	m := new(EchoMsg)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // BouncerServer is the server API for Bouncer service.
 // All implementations must embed UnimplementedBouncerServer
 // for forward compatibility
@@ -93,6 +126,8 @@ type BouncerServer interface {
 	BounceIt(context.Context, *BallIn) (*BallOut, error)
 	GrowTail(context.Context, *Body) (*Body, error)
 	Echo(context.Context, *EchoMsg) (*EchoMsg, error)
+	// echoes every message of the stream back, in order
+	EchoStream(Bouncer_EchoStreamServer) error
 	mustEmbedUnimplementedBouncerServer()
 }
 
@@ -115,6 +150,9 @@ func (UnimplementedBouncerServer) GrowTail(context.Context, *Body) (*Body, error
 func (UnimplementedBouncerServer) Echo(context.Context, *EchoMsg) (*EchoMsg, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Echo not implemented")
 }
+func (UnimplementedBouncerServer) EchoStream(Bouncer_EchoStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method EchoStream not implemented")
+}
 func (UnimplementedBouncerServer) mustEmbedUnimplementedBouncerServer() {}
 
 // UnsafeBouncerServer may be embedded to opt out of forward compatibility for this service.
@@ -218,6 +256,32 @@ func _Bouncer_Echo_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Bouncer_EchoStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BouncerServer).EchoStream(&bouncerEchoStreamServer{stream})
+}
+
+type Bouncer_EchoStreamServer interface {
+	Send(*EchoMsg) error
+	Recv() (*EchoMsg, error)
+	grpc.ServerStream
+}
+
+type bouncerEchoStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *bouncerEchoStreamServer) Send(m *EchoMsg) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *bouncerEchoStreamServer) Recv() (*EchoMsg, error) {
+	m := new(EchoMsg)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Bouncer_ServiceDesc is the grpc.ServiceDesc for Bouncer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -246,6 +310,13 @@ var Bouncer_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _Bouncer_Echo_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "EchoStream",
+			Handler:       _Bouncer_EchoStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "targetservice.proto",
 }
@@ -204,6 +204,11 @@ local wait = reload_module("spec.internal.wait")
   start_grpc_target = grpc.start_grpc_target,
   stop_grpc_target = grpc.stop_grpc_target,
   get_grpc_target_port = grpc.get_grpc_target_port,
+  get_grpc_target_rest_port = grpc.get_grpc_target_rest_port,
+  get_grpc_target_proto_path = grpc.get_grpc_target_proto_path,
+  get_grpc_target_control_port = grpc.get_grpc_target_control_port,
+  get_grpc_target_faults_port = grpc.get_grpc_target_faults_port,
+  dump_grpc_target_channelz = grpc.dump_grpc_target_channelz,
 
   -- plugin compatibility test
   use_old_plugin = misc.use_old_plugin,
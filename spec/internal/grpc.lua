@@ -1,4 +1,5 @@
 local pl_path = require("pl.path")
+local pl_dir = require("pl.dir")
 local shell = require("resty.shell")
 local resty_signal = require("resty.signal")
 
@@ -40,28 +41,98 @@ end
 
 
 local grpc_target_proc
+local grpc_target_protos
+local grpc_target_proxy_protocol
 
 
-local function start_grpc_target()
+-- reference REST transcoding of targetservice.proto, served by the target
+-- itself; see spec/fixtures/grpc/target/rest-gateway.go
+local function get_grpc_target_rest_port()
+  return 15011
+end
+
+
+-- runtime controls of the target, see spec/fixtures/grpc/target/control.go
+local function get_grpc_target_control_port()
+  return 15012
+end
+
+
+-- same services as get_grpc_target_port, served through golang.org/x/net/http2
+-- to inject protocol faults; see spec/fixtures/grpc/target/faults.go
+local function get_grpc_target_faults_port()
+  return 15013
+end
+
+
+--- Builds and starts the gRPC target.
+-- @param opts (optional) table of options:
+--   `instances`: list of extra upstream backends served by the target, each a
+--   table with `id`, `port` and optionally `latency`, `jitter` (durations like
+--   "50ms") and `health` ("SERVING" or "NOT_SERVING"); every reply of an
+--   instance carries its `id` in the `x-instance-id` header
+--   `scenario`: path of a YAML or JSON scenario file overriding the target's
+--   handlers, reloaded with `POST /scenario/reload` on the control port
+--   `proxy_protocol`: when true, every gRPC listener expects a PROXY protocol
+--   v1 or v2 header, and replies carry the real peer and the announced client
+--   in the `x-peer-address` and `x-proxy-client-address` headers
+local function start_grpc_target(opts)
   local ngx_pipe = require("ngx.pipe")
+  opts = opts or {}
+
+  -- the generated */*.pb.go files are checked in; regenerate them with
+  -- `protoc --go_out=. --go-grpc_out=. -I ../ ../<name>.proto` from the
+  -- target directory after changing a .proto
   assert(make(CONSTANTS.GRPC_TARGET_SRC_PATH, {
-    {
-      target = "targetservice/targetservice.pb.go",
-      src    = { "../targetservice.proto" },
-      cmd    = "protoc --go_out=. --go-grpc_out=. -I ../ ../targetservice.proto",
-    },
-    {
-      target = "targetservice/targetservice_grpc.pb.go",
-      src    = { "../targetservice.proto" },
-      cmd    = "protoc --go_out=. --go-grpc_out=. -I ../ ../targetservice.proto",
-    },
     {
       target = "target",
-      src    = { "grpc-target.go", "targetservice/targetservice.pb.go", "targetservice/targetservice_grpc.pb.go" },
+      src    = {
+        "grpc-target.go", "rest-gateway.go", "dump-protos.go", "chat.go",
+        "instances.go", "control.go", "scenario.go", "faults.go", "load.go",
+        "proxy-protocol.go", "channelz.go",
+        "targetservice/targetservice.pb.go", "targetservice/targetservice_grpc.pb.go",
+        "chatservice/chatservice.pb.go", "chatservice/chatservice_grpc.pb.go",
+        "../protos.go", "../targetservice.proto", "../chatservice.proto",
+      },
       cmd    = "go mod tidy && go mod download all && go build",
     },
   }))
-  grpc_target_proc = assert(ngx_pipe.spawn({ CONSTANTS.GRPC_TARGET_SRC_PATH .. "/target" }, {
+
+  -- the protos matching the running target, see get_grpc_target_proto_path
+  grpc_target_protos = pl_path.tmpname()
+  os.remove(grpc_target_protos)
+  local ok, _, stderr = shell.run(string.format("%s/target -dump-protos %s",
+                                                CONSTANTS.GRPC_TARGET_SRC_PATH, grpc_target_protos), nil, 0)
+  assert(ok, stderr)
+
+  local args = {
+    CONSTANTS.GRPC_TARGET_SRC_PATH .. "/target",
+    "-rest", ":" .. get_grpc_target_rest_port(),
+    "-control", ":" .. get_grpc_target_control_port(),
+    "-faults", ":" .. get_grpc_target_faults_port(),
+  }
+  grpc_target_proxy_protocol = opts.proxy_protocol
+  if opts.proxy_protocol then
+    table.insert(args, "-proxy-protocol")
+  end
+  if opts.scenario then
+    table.insert(args, "-scenario")
+    table.insert(args, opts.scenario)
+  end
+  for _, instance in ipairs(opts.instances or {}) do
+    local params = {}
+    for _, k in ipairs({ "latency", "jitter", "health" }) do
+      if instance[k] then
+        table.insert(params, k .. "=" .. instance[k])
+      end
+    end
+
+    table.insert(args, "-instance")
+    table.insert(args, string.format("%s@:%d%s", instance.id, instance.port,
+                                     #params > 0 and "?" .. table.concat(params, "&") or ""))
+  end
+
+  grpc_target_proc = assert(ngx_pipe.spawn(args, {
       merge_stderr = true,
   }))
 
@@ -74,6 +145,11 @@ local function stop_grpc_target()
     grpc_target_proc:kill(resty_signal.signum("QUIT"))
     grpc_target_proc = nil
   end
+
+  if grpc_target_protos then
+    pl_dir.rmtree(grpc_target_protos)
+    grpc_target_protos = nil
+  end
 end
 
 
@@ -82,9 +158,38 @@ local function get_grpc_target_port()
 end
 
 
+-- path of the targetservice.proto written out by the running target,
+-- next to the google/* files it imports
+local function get_grpc_target_proto_path()
+  return grpc_target_protos and pl_path.join(grpc_target_protos, "targetservice.proto")
+end
+
+
+--- Dumps the channelz tree of the running target: its listeners, the
+-- connections Kong opened to them with their call counters, and its own
+-- client channels.  Meant for timeout handlers and debugging hung specs.
+-- @return the tree as a JSON string, or nil and an error message
+local function dump_grpc_target_channelz()
+  local cmd = string.format("%s/target channelz -addr localhost:%d%s",
+                            CONSTANTS.GRPC_TARGET_SRC_PATH, get_grpc_target_port(),
+                            grpc_target_proxy_protocol and " -proxy-protocol" or "")
+  local ok, stdout, stderr = shell.run(cmd, nil, 0)
+  if not ok then
+    return nil, stderr
+  end
+
+  return stdout
+end
+
+
 return {
   start_grpc_target = start_grpc_target,
   stop_grpc_target = stop_grpc_target,
   get_grpc_target_port = get_grpc_target_port,
+  get_grpc_target_rest_port = get_grpc_target_rest_port,
+  get_grpc_target_proto_path = get_grpc_target_proto_path,
+  get_grpc_target_control_port = get_grpc_target_control_port,
+  get_grpc_target_faults_port = get_grpc_target_faults_port,
+  dump_grpc_target_channelz = dump_grpc_target_channelz,
 }
 